@@ -0,0 +1,175 @@
+// Package catalog provides provider templates for new profiles: a built-in
+// catalog embedded in the binary, supplemented by ~/.cc-portkey/catalog.json.
+package catalog
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/nanmi/cc-portkey/internal/config"
+)
+
+const (
+	// Version is the catalog format version this build understands
+	Version = 1
+
+	localFileName = "catalog.json"
+
+	// SourceBuiltIn marks templates that ship with cc-portkey
+	SourceBuiltIn = "built-in"
+)
+
+//go:embed providers.json
+var builtinData []byte
+
+// Template describes how to reach a provider. Its fields mirror the
+// profile fields they fill in.
+type Template struct {
+	DisplayName string            `json:"display_name"`
+	BaseURL     string            `json:"base_url"`
+	KeyEnv      string            `json:"key_env,omitempty"` // env var holding the API key
+	APIKey      string            `json:"api_key,omitempty"` // fixed key, for local servers that ignore it
+	AuthType    string            `json:"auth_type,omitempty"`
+	TimeoutMS   int               `json:"timeout_ms,omitempty"`
+	Models      map[string]string `json:"models,omitempty"`
+
+	// Source is SourceBuiltIn or the path of the local catalog file
+	Source string `json:"-"`
+}
+
+// file is the format of the built-in and local catalog files
+type file struct {
+	Version   int                  `json:"version"`
+	Providers map[string]*Template `json:"providers"`
+}
+
+// Catalog holds templates by provider name
+type Catalog map[string]*Template
+
+// LocalPath returns the path to the user's catalog file
+func LocalPath() (string, error) {
+	dir, err := config.ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, localFileName), nil
+}
+
+// Load returns the built-in catalog with the local catalog file, if any,
+// applied on top. Local templates replace built-in ones of the same name.
+func Load() (Catalog, error) {
+	c := make(Catalog)
+	if err := c.merge(builtinData, SourceBuiltIn); err != nil {
+		return nil, err
+	}
+
+	path, err := LocalPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read catalog: %w", err)
+	}
+	if err := c.merge(data, path); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// merge parses a catalog file and adds its templates, recording source
+func (c Catalog) merge(data []byte, source string) error {
+	var f file
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&f); err != nil {
+		return fmt.Errorf("failed to parse catalog %s: %w", source, err)
+	}
+	if f.Version > Version {
+		return fmt.Errorf("catalog %s version %d is newer than this cc-portkey supports (%d); please upgrade cc-portkey", source, f.Version, Version)
+	}
+	if f.Version < 1 {
+		return fmt.Errorf("catalog %s: invalid version %d", source, f.Version)
+	}
+
+	for name, t := range f.Providers {
+		if err := t.validate(); err != nil {
+			return fmt.Errorf("catalog %s: provider '%s': %w", source, name, err)
+		}
+		t.Source = source
+		c[name] = t
+	}
+	return nil
+}
+
+// validate checks that a template can produce a usable profile
+func (t *Template) validate() error {
+	if t.BaseURL == "" {
+		return fmt.Errorf("base_url is required")
+	}
+	if t.KeyEnv != "" && t.APIKey != "" {
+		return fmt.Errorf("key_env and api_key are both set; keep only one")
+	}
+	if t.TimeoutMS < 0 {
+		return fmt.Errorf("timeout_ms must not be negative")
+	}
+	for key := range t.Models {
+		if _, ok := config.ModelSlotFor(key); !ok {
+			return fmt.Errorf("models.%s is not a model slot", key)
+		}
+	}
+	return nil
+}
+
+// Names returns the provider names in sorted order
+func (c Catalog) Names() []string {
+	names := make([]string, 0, len(c))
+	for name := range c {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Get returns the named template
+func (c Catalog) Get(name string) (*Template, error) {
+	t, ok := c[name]
+	if !ok {
+		return nil, fmt.Errorf("provider '%s' is not in the catalog. Run 'cc-portkey catalog list' to see available providers", name)
+	}
+	return t, nil
+}
+
+// KeyRef returns what the template puts in a profile's api_key
+func (t *Template) KeyRef() string {
+	if t.KeyEnv != "" {
+		return "${" + t.KeyEnv + "}"
+	}
+	return t.APIKey
+}
+
+// Profile returns a new profile filled in from the template
+func (t *Template) Profile() config.Profile {
+	profile := config.Profile{
+		DisplayName: t.DisplayName,
+		BaseURL:     t.BaseURL,
+		AuthType:    t.AuthType,
+		TimeoutMS:   t.TimeoutMS,
+		Models:      make(map[string]string, len(t.Models)),
+	}
+	if key := t.KeyRef(); key != "" {
+		profile.APIKey = config.StringList{key}
+	}
+	for slot, model := range t.Models {
+		profile.Models[slot] = model
+	}
+	return profile
+}
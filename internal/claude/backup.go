@@ -0,0 +1,259 @@
+package claude
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/nanmi/cc-portkey/internal/config"
+	"github.com/nanmi/cc-portkey/internal/fileutil"
+)
+
+const (
+	backupsDirName  = "backups"
+	backupIndexName = "index.json"
+
+	// maxBackups is how many snapshots of each settings file are kept before
+	// the oldest are rotated out
+	maxBackups = 20
+)
+
+// Backup describes one snapshot of a Claude settings file
+type Backup struct {
+	ID      string    `json:"id"`
+	Source  string    `json:"source"` // settings file the snapshot was taken from
+	Created time.Time `json:"created"`
+
+	// Manifest is the manifest entry for Source when the snapshot was taken,
+	// nil if there was none, and is put back with the file
+	Manifest *ManifestEntry `json:"manifest,omitempty"`
+}
+
+// backupsDir returns ~/.cc-portkey/backups
+func backupsDir() (string, error) {
+	dir, err := config.StateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, backupsDirName), nil
+}
+
+// BackupPath returns the path of the snapshot file for a backup ID
+func BackupPath(id string) (string, error) {
+	dir, err := backupsDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, id+".json"), nil
+}
+
+// ListBackups returns all snapshots, newest first
+func ListBackups() ([]Backup, error) {
+	dir, err := backupsDir()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, backupIndexName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read backup index: %w", err)
+	}
+
+	var backups []Backup
+	if err := json.Unmarshal(data, &backups); err != nil {
+		return nil, fmt.Errorf("failed to parse backup index: %w", err)
+	}
+
+	sort.SliceStable(backups, func(i, j int) bool {
+		return backups[i].Created.After(backups[j].Created)
+	})
+
+	return backups, nil
+}
+
+// FindBackup looks up a snapshot by ID. An empty ID returns the newest one.
+func FindBackup(id string) (*Backup, error) {
+	backups, err := ListBackups()
+	if err != nil {
+		return nil, err
+	}
+	if len(backups) == 0 {
+		return nil, fmt.Errorf("no backups found")
+	}
+	if id == "" {
+		return &backups[0], nil
+	}
+	for i := range backups {
+		if backups[i].ID == id {
+			return &backups[i], nil
+		}
+	}
+	return nil, fmt.Errorf("backup '%s' not found. Run 'cc-portkey restore --list' to see available backups", id)
+}
+
+// RestoreContents returns the current contents of the file a snapshot was
+// taken from, nil if it doesn't exist, and the snapshot itself. Both are read
+// under the settings lock so a diff between them never sees a half-written
+// switch.
+func RestoreContents(backup *Backup) (current, snapshot []byte, err error) {
+	err = withSettingsLock(func() error {
+		if snapshot, err = readSnapshot(backup); err != nil {
+			return err
+		}
+		current, err = readSource(backup.Source)
+		return err
+	})
+	return current, snapshot, err
+}
+
+// RestoreBackup writes a snapshot back to the file it was taken from, along
+// with the manifest entry recorded for it, so the next switch removes the env
+// keys the restored file got from cc-portkey and nothing else. The file being
+// replaced is itself backed up first. current is the content the caller
+// showed the diff against, from RestoreContents; if the file has changed
+// since, nothing is written.
+func RestoreBackup(backup *Backup, current []byte) error {
+	return withSettingsLock(func() error {
+		data, err := readSnapshot(backup)
+		if err != nil {
+			return err
+		}
+		now, err := readSource(backup.Source)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(now, current) {
+			return fmt.Errorf("%s changed since the diff was shown; run 'cc-portkey restore' again", backup.Source)
+		}
+
+		if err := writeSettingsFile(backup.Source, data); err != nil {
+			return err
+		}
+
+		manifest, err := LoadManifest()
+		if err != nil {
+			return err
+		}
+		if backup.Manifest != nil {
+			manifest.Files[backup.Source] = *backup.Manifest
+		} else {
+			delete(manifest.Files, backup.Source)
+		}
+		return manifest.save()
+	})
+}
+
+// readSnapshot reads the snapshot file of a backup
+func readSnapshot(backup *Backup) ([]byte, error) {
+	path, err := BackupPath(backup.ID)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup: %w", err)
+	}
+	return data, nil
+}
+
+// readSource reads the settings file a backup was taken from, returning nil
+// if it doesn't exist
+func readSource(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read settings file: %w", err)
+	}
+	return data, nil
+}
+
+// backupSettings snapshots the settings file at path, if it exists, and
+// rotates out the oldest snapshots of that file beyond maxBackups.
+// The caller must hold the settings lock.
+func backupSettings(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read settings file for backup: %w", err)
+	}
+
+	dir, err := backupsDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create backups directory: %w", err)
+	}
+
+	backups, err := ListBackups()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	id := now.Format("20060102-150405.000")
+	for n := 2; backupExists(backups, id); n++ {
+		id = fmt.Sprintf("%s-%d", now.Format("20060102-150405.000"), n)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, id+".json"), data, 0600); err != nil {
+		return fmt.Errorf("failed to write backup: %w", err)
+	}
+
+	manifest, err := LoadManifest()
+	if err != nil {
+		return err
+	}
+	backup := Backup{ID: id, Source: path, Created: now}
+	if entry, ok := manifest.Files[path]; ok {
+		backup.Manifest = &entry
+	}
+
+	// Backups are newest first, so past maxBackups of one source the rest
+	// of its snapshots are the oldest
+	backups = append([]Backup{backup}, backups...)
+	kept := backups[:0]
+	perSource := make(map[string]int)
+	for _, b := range backups {
+		perSource[b.Source]++
+		if perSource[b.Source] > maxBackups {
+			os.Remove(filepath.Join(dir, b.ID+".json"))
+			continue
+		}
+		kept = append(kept, b)
+	}
+
+	return saveBackupIndex(dir, kept)
+}
+
+// backupExists reports whether id is already used by a snapshot
+func backupExists(backups []Backup, id string) bool {
+	for _, b := range backups {
+		if b.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// saveBackupIndex writes the backup index
+func saveBackupIndex(dir string, backups []Backup) error {
+	data, err := json.MarshalIndent(backups, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal backup index: %w", err)
+	}
+
+	if err := fileutil.WriteFileAtomic(filepath.Join(dir, backupIndexName), data, 0600); err != nil {
+		return fmt.Errorf("failed to save backup index: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,174 @@
+package claude
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/nanmi/cc-portkey/internal/config"
+)
+
+// managedEnvKeys lists every env key ProfileEnv can produce, along with
+// ANTHROPIC_SMALL_FAST_MODEL, which earlier versions wrote
+var managedEnvKeys = func() []string {
+	keys := append(legacyEnvKeys,
+		"ANTHROPIC_API_KEY",
+		"ANTHROPIC_CUSTOM_HEADERS",
+	)
+	for _, slot := range config.ModelSlots {
+		if !containsKey(keys, slot.EnvVar) {
+			keys = append(keys, slot.EnvVar)
+		}
+	}
+	return keys
+}()
+
+// legacyEnvKeys lists the env keys written before the manifest recorded
+// them; see Manifest.OwnedEnvKeys
+var legacyEnvKeys = []string{
+	"ANTHROPIC_BASE_URL",
+	"ANTHROPIC_AUTH_TOKEN",
+	"API_TIMEOUT_MS",
+	"ANTHROPIC_MODEL",
+	"ANTHROPIC_SMALL_FAST_MODEL",
+	"ANTHROPIC_DEFAULT_OPUS_MODEL",
+	"ANTHROPIC_DEFAULT_SONNET_MODEL",
+	"ANTHROPIC_DEFAULT_HAIKU_MODEL",
+	"CLAUDE_CODE_DISABLE_NONESSENTIAL_TRAFFIC",
+}
+
+// ProfileEnv returns the environment variables a profile sets for Claude Code.
+// Managed keys missing from the result must be cleared, which for
+// subscription profiles removes every auth and base URL key.
+func ProfileEnv(profile *config.Profile) (map[string]string, error) {
+	env := make(map[string]string)
+
+	if err := ValidateAuth(profile); err != nil {
+		return nil, err
+	}
+	if err := ValidateEnv(profile); err != nil {
+		return nil, err
+	}
+
+	// Expand references and run key/URL commands
+	resolved, err := profile.Resolve()
+	if err != nil {
+		return nil, err
+	}
+	apiKey := resolved.APIKey
+	baseURL := resolved.BaseURL
+
+	// Apply base_url (empty after expansion means use official API)
+	if baseURL != "" {
+		env["ANTHROPIC_BASE_URL"] = baseURL
+	}
+
+	// Apply API key as the header the auth type asks for
+	if profile.SendsKey() {
+		if profile.AuthType == config.AuthXAPIKey {
+			env["ANTHROPIC_API_KEY"] = apiKey
+		} else {
+			env["ANTHROPIC_AUTH_TOKEN"] = apiKey
+		}
+	}
+
+	// Apply extra headers, one "Name: value" per line
+	if len(resolved.Headers) > 0 {
+		env["ANTHROPIC_CUSTOM_HEADERS"] = formatHeaders(resolved.Headers)
+	}
+
+	// Apply timeout
+	if profile.TimeoutMS > 0 {
+		env["API_TIMEOUT_MS"] = strconv.Itoa(profile.TimeoutMS)
+	}
+
+	// Apply models
+	for key, model := range profile.ModelEnv() {
+		env[key] = model
+	}
+
+	// Disable nonessential traffic for third-party providers
+	if baseURL != "" {
+		env["CLAUDE_CODE_DISABLE_NONESSENTIAL_TRAFFIC"] = "1"
+	}
+
+	// Apply extra env
+	for key, value := range resolved.Env {
+		env[key] = value
+	}
+
+	return env, nil
+}
+
+// ValidateAuth checks a profile's kind and auth_type, and that
+// custom_headers comes with headers to send
+func ValidateAuth(profile *config.Profile) error {
+	switch profile.Kind {
+	case "", config.KindAPI:
+	case config.KindSubscription:
+		return nil
+	default:
+		return fmt.Errorf("unknown kind %q (expected %s or %s)", profile.Kind, config.KindAPI, config.KindSubscription)
+	}
+
+	switch profile.AuthType {
+	case "", config.AuthBearer, config.AuthXAPIKey, config.AuthNone:
+		return nil
+	case config.AuthCustomHeaders:
+		if len(profile.Headers) == 0 {
+			return fmt.Errorf("auth_type %s requires headers", config.AuthCustomHeaders)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown auth_type %q (expected %s, %s, %s or %s)", profile.AuthType,
+		config.AuthBearer, config.AuthXAPIKey, config.AuthNone, config.AuthCustomHeaders)
+}
+
+// ValidateEnv checks that a profile's extra env leaves the keys cc-portkey
+// manages to the profile's own fields
+func ValidateEnv(profile *config.Profile) error {
+	var conflicts []string
+	for key := range profile.Env {
+		if isManaged(key) {
+			conflicts = append(conflicts, key)
+		}
+	}
+	if len(conflicts) == 0 {
+		return nil
+	}
+	sort.Strings(conflicts)
+	return fmt.Errorf("env sets %s, which cc-portkey manages; use base_url, api_key, auth_type, headers, timeout_ms or models instead",
+		strings.Join(conflicts, ", "))
+}
+
+// isManaged reports whether key is one of the env keys ProfileEnv sets itself
+func isManaged(key string) bool {
+	return containsKey(managedEnvKeys, key)
+}
+
+// containsKey reports whether keys contains key
+func containsKey(keys []string, key string) bool {
+	for _, k := range keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// formatHeaders renders headers in the ANTHROPIC_CUSTOM_HEADERS format,
+// sorted by name so the value is stable across switches
+func formatHeaders(headers map[string]string) string {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	lines := make([]string, len(names))
+	for i, name := range names {
+		lines[i] = name + ": " + headers[name]
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,103 @@
+package claude
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/nanmi/cc-portkey/internal/config"
+	"github.com/nanmi/cc-portkey/internal/fileutil"
+)
+
+const manifestFileName = "manifest.json"
+
+// ManifestEntry records what cc-portkey applied to one settings file
+type ManifestEntry struct {
+	Profile string   `json:"profile"`
+	Env     []string `json:"env"` // env keys cc-portkey wrote
+
+	// Paths maps the settings outside env that cc-portkey wrote, as dotted
+	// paths, to the value each had before (null if it was absent) so they
+	// can be put back
+	Paths map[string]json.RawMessage `json:"paths,omitempty"`
+}
+
+// Manifest tracks every Claude settings file cc-portkey has written,
+// keyed by the absolute path of the settings file
+type Manifest struct {
+	Files map[string]ManifestEntry `json:"files"`
+}
+
+// manifestPath returns the path to the sidecar manifest
+func manifestPath() (string, error) {
+	dir, err := config.StateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, manifestFileName), nil
+}
+
+// LoadManifest reads the manifest, returning an empty one if it doesn't exist
+func LoadManifest() (*Manifest, error) {
+	path, err := manifestPath()
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &Manifest{Files: make(map[string]ManifestEntry)}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return manifest, nil
+		}
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest %s: %w", path, err)
+	}
+	if manifest.Files == nil {
+		manifest.Files = make(map[string]ManifestEntry)
+	}
+
+	return manifest, nil
+}
+
+// save writes the manifest; the caller must hold the settings lock
+func (m *Manifest) save() error {
+	path, err := manifestPath()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+
+	if err := fileutil.WriteFileAtomic(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to save manifest: %w", err)
+	}
+
+	return nil
+}
+
+// OwnedEnvKeys returns the env keys cc-portkey wrote to the settings file at path.
+// The user settings file, the only one written before keys were recorded, is
+// then assumed to hold every key ProfileEnv could produce at the time; any
+// other file without an entry holds none of ours.
+func (m *Manifest) OwnedEnvKeys(path string) []string {
+	if entry, ok := m.Files[path]; ok && entry.Env != nil {
+		return entry.Env
+	}
+	if userPath, err := SettingsPath(); err == nil && path == userPath {
+		return legacyEnvKeys
+	}
+	return nil
+}
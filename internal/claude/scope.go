@@ -0,0 +1,117 @@
+package claude
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const localSettingsFileName = "settings.local.json"
+
+// Scope selects which Claude Code settings file a profile is written to
+type Scope string
+
+const (
+	// ScopeUser is ~/.claude/settings.json and applies to every project
+	ScopeUser Scope = "user"
+	// ScopeProject is <repo>/.claude/settings.json, usually committed
+	ScopeProject Scope = "project"
+	// ScopeLocal is <repo>/.claude/settings.local.json, usually git-ignored
+	ScopeLocal Scope = "local"
+)
+
+// Scopes lists all scopes from highest to lowest precedence, matching Claude Code
+var Scopes = []Scope{ScopeLocal, ScopeProject, ScopeUser}
+
+// ParseScope converts a --scope flag value into a Scope
+func ParseScope(s string) (Scope, error) {
+	switch Scope(s) {
+	case ScopeUser, ScopeProject, ScopeLocal:
+		return Scope(s), nil
+	case "":
+		return ScopeUser, nil
+	}
+	return "", fmt.Errorf("invalid scope '%s' (expected user, project or local)", s)
+}
+
+// ProjectRoot returns the root of the project containing the working directory.
+// It walks up to the nearest directory with a .git entry and falls back to
+// the working directory itself outside of a repository.
+func ProjectRoot() (string, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", fmt.Errorf("failed to get working directory: %w", err)
+	}
+
+	dir := cwd
+	for {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dir, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return cwd, nil
+		}
+		dir = parent
+	}
+}
+
+// SettingsPathFor returns the settings file for the given scope
+func SettingsPathFor(scope Scope) (string, error) {
+	switch scope {
+	case ScopeUser, "":
+		return SettingsPath()
+	case ScopeProject, ScopeLocal:
+		root, err := ProjectRoot()
+		if err != nil {
+			return "", err
+		}
+		name := settingsFileName
+		if scope == ScopeLocal {
+			name = localSettingsFileName
+		}
+		return filepath.Join(root, claudeDirName, name), nil
+	}
+	return "", fmt.Errorf("invalid scope '%s'", scope)
+}
+
+// Active describes the settings file that decides the provider for the working directory
+type Active struct {
+	Scope   Scope
+	Path    string
+	Profile string // empty if the file was not written by cc-portkey
+}
+
+// ActiveScope reports the highest-precedence settings file that cc-portkey
+// has applied a profile to. Project and local files only count while they
+// still exist; the user scope is returned as the fallback.
+func ActiveScope() (*Active, error) {
+	manifest, err := LoadManifest()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, scope := range Scopes {
+		path, err := SettingsPathFor(scope)
+		if err != nil {
+			return nil, err
+		}
+		entry, ok := manifest.Files[path]
+		if scope == ScopeUser {
+			active := &Active{Scope: scope, Path: path}
+			if ok {
+				active.Profile = entry.Profile
+			}
+			return active, nil
+		}
+		if !ok {
+			continue
+		}
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		return &Active{Scope: scope, Path: path, Profile: entry.Profile}, nil
+	}
+
+	return nil, nil
+}
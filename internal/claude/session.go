@@ -0,0 +1,122 @@
+package claude
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/nanmi/cc-portkey/internal/config"
+)
+
+const sessionsDirName = "sessions"
+
+// SessionEnv returns the process environment for an env-only launch:
+// the current environment with managed keys and the profile's extra env
+// replaced by the profile's values
+func SessionEnv(profileEnv map[string]string) []string {
+	environ := make([]string, 0, len(os.Environ())+len(profileEnv))
+	for _, kv := range os.Environ() {
+		key, _, _ := strings.Cut(kv, "=")
+		if _, ok := profileEnv[key]; ok || isManaged(key) {
+			continue
+		}
+		environ = append(environ, kv)
+	}
+	for key, value := range profileEnv {
+		environ = append(environ, key+"="+value)
+	}
+	return environ
+}
+
+// WriteSessionSettings writes a settings file for an env-only launch and
+// returns its path. Claude Code applies the env block of settings.json over
+// the process environment, so the launch passes this file via --settings to
+// make the profile win over whatever settings.json contains. Managed keys the
+// profile doesn't set are blanked; its extra env and settings overlay are
+// written as is.
+//
+// The file is named after the current PID, which the exec'd claude process
+// keeps, and is removed by a later launch once that process has exited.
+func WriteSessionSettings(profileEnv map[string]string, overlay map[string]interface{}) (string, error) {
+	dir, err := config.ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	dir = filepath.Join(dir, sessionsDirName)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create sessions directory: %w", err)
+	}
+
+	cleanSessions(dir)
+
+	env := make(map[string]string, len(managedEnvKeys)+len(profileEnv))
+	for _, key := range managedEnvKeys {
+		env[key] = profileEnv[key]
+	}
+	for key, value := range profileEnv {
+		env[key] = value
+	}
+
+	settings := map[string]interface{}{"env": env}
+	for p, value := range overlay {
+		setPath(settings, splitPath(p), value)
+	}
+
+	data, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal session settings: %w", err)
+	}
+
+	path := filepath.Join(dir, strconv.Itoa(os.Getpid())+".json")
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return "", fmt.Errorf("failed to write session settings: %w", err)
+	}
+
+	return path, nil
+}
+
+// setPath sets a nested value in obj, creating objects along path
+func setPath(obj map[string]interface{}, path []string, value interface{}) {
+	for _, key := range path[:len(path)-1] {
+		child, ok := obj[key].(map[string]interface{})
+		if !ok {
+			child = make(map[string]interface{})
+			obj[key] = child
+		}
+		obj = child
+	}
+	obj[path[len(path)-1]] = value
+}
+
+// cleanSessions removes session files whose claude process has exited
+func cleanSessions(dir string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(strings.TrimSuffix(entry.Name(), ".json"))
+		if err != nil || processAlive(pid) {
+			continue
+		}
+		os.Remove(filepath.Join(dir, entry.Name()))
+	}
+}
+
+// processAlive reports whether a process with the given PID is running
+func processAlive(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	// FindProcess only succeeds for live processes on Windows
+	if runtime.GOOS == "windows" {
+		return true
+	}
+	return p.Signal(syscall.Signal(0)) == nil
+}
@@ -1,22 +1,26 @@
 package claude
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
-	"regexp"
-	"strconv"
+	"sort"
+	"strings"
 
 	"github.com/nanmi/cc-portkey/internal/config"
+	"github.com/nanmi/cc-portkey/internal/fileutil"
+	"github.com/nanmi/cc-portkey/internal/jsonc"
 )
 
 const (
-	claudeDirName      = ".claude"
-	settingsFileName   = "settings.json"
+	claudeDirName    = ".claude"
+	settingsFileName = "settings.json"
+	settingsLockName = "settings.lock"
 )
 
-// SettingsPath returns the path to Claude's settings.json
+// SettingsPath returns the path to Claude's user-level settings.json
 func SettingsPath() (string, error) {
 	home, err := os.UserHomeDir()
 	if err != nil {
@@ -29,143 +33,317 @@ func SettingsPath() (string, error) {
 // We use map[string]interface{} to preserve unknown fields
 type Settings map[string]interface{}
 
-// removeTrailingCommas removes trailing commas from JSON that would cause parse errors
-// This handles cases like: {"key": "value",} -> {"key": "value"}
-func removeTrailingCommas(data []byte) []byte {
-	// Remove trailing commas before } or ]
-	re := regexp.MustCompile(`,\s*([\]}])`)
-	return re.ReplaceAll(data, []byte("$1"))
-}
+// Load reads the Claude settings file for the given scope.
+// Comments and trailing commas (common in hand-edited files) are accepted.
+func Load(scope Scope) (Settings, error) {
+	path, err := SettingsPathFor(scope)
+	if err != nil {
+		return nil, err
+	}
 
-// Load reads Claude's settings.json
-func Load() (Settings, error) {
-	path, err := SettingsPath()
+	doc, err := readDocument(path)
 	if err != nil {
 		return nil, err
 	}
 
-	data, err := os.ReadFile(path)
+	settings, err := doc.Value()
 	if err != nil {
-		if os.IsNotExist(err) {
-			// Return empty settings if file doesn't exist
-			return Settings{}, nil
-		}
-		return nil, fmt.Errorf("failed to read settings file: %w", err)
+		return nil, fmt.Errorf("failed to parse settings file %s: %w", path, err)
 	}
 
-	// Clean up JSON with trailing commas (common in hand-edited files)
-	data = removeTrailingCommas(data)
+	return settings, nil
+}
+
+// readDocument parses the settings file at path, returning an empty
+// document if it doesn't exist
+func readDocument(path string) (*jsonc.Document, error) {
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read settings file: %w", err)
+	}
 
-	var settings Settings
-	if err := json.Unmarshal(data, &settings); err != nil {
-		return nil, fmt.Errorf("failed to parse settings file: %w", err)
+	doc, err := jsonc.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse settings file %s: %w", path, err)
 	}
 
-	return settings, nil
+	return doc, nil
 }
 
-// Save writes Claude's settings.json
-func Save(settings Settings) error {
-	path, err := SettingsPath()
+// editSettings applies fn to the settings file at path and writes the result
+// back if anything changed. Only the values fn touches are rewritten;
+// comments, key order and formatting elsewhere are preserved.
+func editSettings(path string, fn func(doc *jsonc.Document) error) error {
+	original, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read settings file: %w", err)
+	}
+
+	doc, err := jsonc.Parse(original)
 	if err != nil {
+		return fmt.Errorf("failed to parse settings file %s: %w", path, err)
+	}
+
+	if err := fn(doc); err != nil {
 		return err
 	}
 
+	if original != nil && bytes.Equal(doc.Bytes(), original) {
+		return nil
+	}
+	return writeSettingsFile(path, doc.Bytes())
+}
+
+// writeSettingsFile snapshots the existing file into the backups directory
+// and then atomically replaces it with data. The caller must hold the settings lock.
+func writeSettingsFile(path string, data []byte) error {
 	dir := filepath.Dir(path)
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return fmt.Errorf("failed to create claude config directory: %w", err)
 	}
 
-	data, err := json.MarshalIndent(settings, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal settings: %w", err)
-	}
+	fileutil.CleanTemps(path)
 
-	// Write to temp file first for atomic operation
-	tmpPath := path + ".tmp"
-	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
-		return fmt.Errorf("failed to write settings file: %w", err)
+	if err := backupSettings(path); err != nil {
+		return err
 	}
 
-	if err := os.Rename(tmpPath, path); err != nil {
-		os.Remove(tmpPath)
+	if err := fileutil.WriteFileAtomic(path, data, 0644); err != nil {
 		return fmt.Errorf("failed to save settings file: %w", err)
 	}
 
 	return nil
 }
 
-// ApplyProfile applies a profile's settings to Claude's settings.json
-func ApplyProfile(profile *config.Profile) error {
-	settings, err := Load()
+// withSettingsLock runs fn while holding the lock that serializes every
+// read-modify-write of Claude settings files, the manifest and the backups
+// across cc-portkey processes
+func withSettingsLock(fn func() error) error {
+	dir, err := config.StateDir()
 	if err != nil {
 		return err
 	}
+	return fileutil.WithLock(filepath.Join(dir, settingsLockName), fn)
+}
 
-	// Get or create env map
-	env, ok := settings["env"].(map[string]interface{})
-	if !ok {
-		env = make(map[string]interface{})
+// Applied is what a profile writes into a settings file
+type Applied struct {
+	Env      map[string]string      // from ProfileEnv
+	Settings map[string]interface{} // other settings by dotted path, e.g. "apiKeyHelper"
+}
+
+// ApplyProfile applies a profile's settings to the settings file for the given scope.
+// Env keys written by the previously applied profile are removed first, and the
+// keys written now are recorded in the manifest so the next switch can do the same.
+// Other settings are recorded with their previous values and put back once no
+// profile sets them. applied.Env comes from ProfileEnv; resolving it beforehand
+// keeps key commands and passphrase prompts outside the settings lock.
+func ApplyProfile(name string, applied *Applied, scope Scope) error {
+	path, err := SettingsPathFor(scope)
+	if err != nil {
+		return err
 	}
 
-	// Expand environment variables
-	apiKey := config.ExpandEnv(profile.APIKey)
-	baseURL := config.ExpandEnv(profile.BaseURL)
+	return withSettingsLock(func() error {
+		return applyProfile(path, name, applied)
+	})
+}
 
-	// Apply base_url (empty after expansion means use official API)
-	if baseURL != "" {
-		env["ANTHROPIC_BASE_URL"] = baseURL
-	} else {
-		delete(env, "ANTHROPIC_BASE_URL")
+// applyProfile does the work of ApplyProfile under the settings lock
+func applyProfile(path, name string, applied *Applied) error {
+	manifest, err := LoadManifest()
+	if err != nil {
+		return err
 	}
 
-	// Apply API key
-	env["ANTHROPIC_AUTH_TOKEN"] = apiKey
+	owned := make([]string, 0, len(applied.Env))
+	for key := range applied.Env {
+		owned = append(owned, key)
+	}
+	sort.Strings(owned)
 
-	// Apply timeout
-	if profile.TimeoutMS > 0 {
-		env["API_TIMEOUT_MS"] = strconv.Itoa(profile.TimeoutMS)
+	settingsPaths := make([]string, 0, len(applied.Settings))
+	for p := range applied.Settings {
+		settingsPaths = append(settingsPaths, p)
 	}
+	sort.Strings(settingsPaths)
+
+	previous := manifest.Files[path].Paths
+	originals := make(map[string]json.RawMessage)
+
+	err = editSettings(path, func(doc *jsonc.Document) error {
+		// Remove what the previous profile added but this one doesn't set;
+		// keys set by both are replaced in place to keep their position
+		for _, key := range manifest.OwnedEnvKeys(path) {
+			if _, ok := applied.Env[key]; ok {
+				continue
+			}
+			if _, err := doc.Delete([]string{"env", key}); err != nil {
+				return err
+			}
+		}
+		for _, key := range owned {
+			if err := doc.Set([]string{"env", key}, applied.Env[key]); err != nil {
+				return err
+			}
+		}
 
-	// Apply models
-	if model, ok := profile.Models["default"]; ok && model != "" {
-		env["ANTHROPIC_MODEL"] = model
-	} else {
-		delete(env, "ANTHROPIC_MODEL")
+		if err := restorePaths(doc, previous, applied.Settings); err != nil {
+			return err
+		}
+		for _, p := range settingsPaths {
+			// Keep the value from before cc-portkey first took the path over
+			if original, ok := previous[p]; ok {
+				originals[p] = original
+			} else if originals[p], err = currentValue(doc, p); err != nil {
+				return err
+			}
+			if applied.Settings[p] == nil {
+				if err := deletePath(doc, splitPath(p)); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := doc.Set(splitPath(p), applied.Settings[p]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	entry := ManifestEntry{Profile: name, Env: owned}
+	if len(originals) > 0 {
+		entry.Paths = originals
 	}
+	manifest.Files[path] = entry
+	return manifest.save()
+}
 
-	if model, ok := profile.Models["small_fast"]; ok && model != "" {
-		env["ANTHROPIC_SMALL_FAST_MODEL"] = model
-	} else {
-		delete(env, "ANTHROPIC_SMALL_FAST_MODEL")
+// restorePaths puts back the original value of every recorded path that
+// keep doesn't set
+func restorePaths(doc *jsonc.Document, originals map[string]json.RawMessage, keep map[string]interface{}) error {
+	for p, original := range originals {
+		if _, ok := keep[p]; ok {
+			continue
+		}
+		var value interface{}
+		if err := json.Unmarshal(original, &value); err != nil {
+			return fmt.Errorf("invalid original value for %s in manifest: %w", p, err)
+		}
+		if value == nil {
+			if err := deletePath(doc, splitPath(p)); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := doc.Set(splitPath(p), value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deletePath removes the value at path along with the objects around it
+// that are left empty, so reverting a nested setting leaves no "{}" behind
+func deletePath(doc *jsonc.Document, path []string) error {
+	if _, err := doc.Delete(path); err != nil {
+		return err
+	}
+	for parent := path[:len(path)-1]; len(parent) > 0; parent = parent[:len(parent)-1] {
+		value, _ := doc.Get(parent...)
+		if obj, ok := value.(map[string]interface{}); !ok || len(obj) > 0 {
+			break
+		}
+		if _, err := doc.Delete(parent); err != nil {
+			return err
+		}
 	}
+	return nil
+}
 
-	if model, ok := profile.Models["opus"]; ok && model != "" {
-		env["ANTHROPIC_DEFAULT_OPUS_MODEL"] = model
-	} else {
-		delete(env, "ANTHROPIC_DEFAULT_OPUS_MODEL")
+// currentValue returns the JSON value at a dotted path, or null if absent
+func currentValue(doc *jsonc.Document, p string) (json.RawMessage, error) {
+	value, ok := doc.Get(splitPath(p)...)
+	if !ok {
+		return json.RawMessage("null"), nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record %s: %w", p, err)
 	}
+	return data, nil
+}
+
+// splitPath splits a dotted settings path into its keys
+func splitPath(p string) []string {
+	return strings.Split(p, ".")
+}
 
-	if model, ok := profile.Models["sonnet"]; ok && model != "" {
-		env["ANTHROPIC_DEFAULT_SONNET_MODEL"] = model
-	} else {
-		delete(env, "ANTHROPIC_DEFAULT_SONNET_MODEL")
+// Reset removes every env key cc-portkey manages from the settings file for
+// the given scope, returning Claude Code to its own defaults there.
+// It returns the env keys removed and the other settings put back.
+func Reset(scope Scope) ([]string, error) {
+	path, err := SettingsPathFor(scope)
+	if err != nil {
+		return nil, err
 	}
 
-	if model, ok := profile.Models["haiku"]; ok && model != "" {
-		env["ANTHROPIC_DEFAULT_HAIKU_MODEL"] = model
-	} else {
-		delete(env, "ANTHROPIC_DEFAULT_HAIKU_MODEL")
+	var removed []string
+	err = withSettingsLock(func() error {
+		removed, err = reset(path)
+		return err
+	})
+	return removed, err
+}
+
+// reset does the work of Reset under the settings lock
+func reset(path string) ([]string, error) {
+	manifest, err := LoadManifest()
+	if err != nil {
+		return nil, err
 	}
 
-	// Disable nonessential traffic for third-party providers
-	if baseURL != "" {
-		env["CLAUDE_CODE_DISABLE_NONESSENTIAL_TRAFFIC"] = "1"
-	} else {
-		delete(env, "CLAUDE_CODE_DISABLE_NONESSENTIAL_TRAFFIC")
+	var removed []string
+	if _, err := os.Stat(path); err == nil {
+		err := editSettings(path, func(doc *jsonc.Document) error {
+			for _, key := range manifest.OwnedEnvKeys(path) {
+				ok, err := doc.Delete([]string{"env", key})
+				if err != nil {
+					return err
+				}
+				if ok {
+					removed = append(removed, key)
+				}
+			}
+			if env, ok := doc.Get("env"); ok && len(removed) > 0 {
+				if m, ok := env.(map[string]interface{}); ok && len(m) == 0 {
+					if _, err := doc.Delete([]string{"env"}); err != nil {
+						return err
+					}
+				}
+			}
+
+			paths := manifest.Files[path].Paths
+			for p := range paths {
+				removed = append(removed, p)
+			}
+			return restorePaths(doc, paths, nil)
+		})
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	settings["env"] = env
+	if _, ok := manifest.Files[path]; ok {
+		delete(manifest.Files, path)
+		if err := manifest.save(); err != nil {
+			return nil, err
+		}
+	}
 
-	return Save(settings)
+	sort.Strings(removed)
+	return removed, nil
 }
@@ -6,26 +6,57 @@ import (
 	"os"
 	"strings"
 
+	"github.com/nanmi/cc-portkey/internal/catalog"
 	"github.com/nanmi/cc-portkey/internal/config"
 	"github.com/spf13/cobra"
 )
 
 var addCmd = &cobra.Command{
-	Use:    "add <profile>",
-	Short:  "Add a new profile interactively",
-	Hidden: true, // Use 'edit' instead
+	Use:   "add <profile>",
+	Short: "Add a new profile interactively, from flags or from the provider catalog",
 	Long: `Add a new provider profile with interactive prompts.
 
 You will be asked to provide:
   - Display name
   - Base URL
   - API key (can use ${ENV_VAR} syntax)
-  - Timeout (optional)`,
+  - Timeout (optional)
+
+With any of the flags below the profile is created without prompts, which
+suits provisioning scripts. --from starts from a provider template; run
+'cc-portkey catalog list' to see the providers. The other flags set or
+override individual fields.
+
+Examples:
+  cc-portkey add kimi --from kimi
+  cc-portkey add local --from ollama --model default=qwen3-coder:30b
+  cc-portkey add proxy --base-url https://proxy.example.com/anthropic \
+      --api-key-env PROXY_API_KEY --timeout 600000 --model opus=claude-opus-4-1`,
 	Args: cobra.ExactArgs(1),
 	RunE: runAdd,
 }
 
+var (
+	addFrom        string
+	addDisplayName string
+	addBaseURL     string
+	addAPIKey      string
+	addAPIKeyEnv   string
+	addTimeout     int
+	addModels      []string
+)
+
+// addFlags are the flags that make add skip its prompts
+var addFlags = []string{"from", "display-name", "base-url", "api-key", "api-key-env", "timeout", "model"}
+
 func init() {
+	addCmd.Flags().StringVar(&addFrom, "from", "", "create the profile from a provider template in the catalog")
+	addCmd.Flags().StringVar(&addDisplayName, "display-name", "", "human-readable name (default the profile name)")
+	addCmd.Flags().StringVar(&addBaseURL, "base-url", "", "API endpoint URL")
+	addCmd.Flags().StringVar(&addAPIKey, "api-key", "", "API key or ${VAR} reference")
+	addCmd.Flags().StringVar(&addAPIKeyEnv, "api-key-env", "", "environment variable holding the API key")
+	addCmd.Flags().IntVar(&addTimeout, "timeout", 120000, "request timeout in milliseconds")
+	addCmd.Flags().StringArrayVar(&addModels, "model", nil, "model for a slot as <slot>=<model>, e.g. opus=glm-4.6 (repeatable)")
 	rootCmd.AddCommand(addCmd)
 }
 
@@ -44,6 +75,12 @@ func runAdd(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("profile '%s' already exists. Use 'cc-portkey edit' to modify it", profileName)
 	}
 
+	for _, name := range addFlags {
+		if cmd.Flags().Changed(name) {
+			return addFromFlags(cmd, profileName)
+		}
+	}
+
 	reader := bufio.NewReader(os.Stdin)
 
 	fmt.Printf("Adding new profile: %s\n\n", cyan(profileName))
@@ -78,15 +115,13 @@ func runAdd(cmd *cobra.Command, args []string) error {
 	profile := config.Profile{
 		DisplayName: displayName,
 		BaseURL:     baseURL,
-		APIKey:      apiKey,
+		APIKey:      config.StringList{apiKey},
 		TimeoutMS:   timeout,
 		Models:      make(map[string]string),
 	}
 
-	cfg.Profiles[profileName] = profile
-
-	if err := config.Save(cfg); err != nil {
-		return fmt.Errorf("failed to save config: %w", err)
+	if err := saveNewProfile(profileName, profile); err != nil {
+		return err
 	}
 
 	fmt.Printf("\n%s Profile '%s' added successfully.\n", green("OK"), profileName)
@@ -94,3 +129,110 @@ func runAdd(cmd *cobra.Command, args []string) error {
 
 	return nil
 }
+
+// addFromFlags creates a profile from the provider template named by
+// --from, if any, and the field flags
+func addFromFlags(cmd *cobra.Command, profileName string) error {
+	flags := cmd.Flags()
+	profile := config.Profile{
+		DisplayName: profileName,
+		TimeoutMS:   addTimeout,
+		Models:      make(map[string]string),
+	}
+	keyEnv := ""
+
+	if addFrom != "" {
+		c, err := catalog.Load()
+		if err != nil {
+			return err
+		}
+		t, err := c.Get(addFrom)
+		if err != nil {
+			return err
+		}
+		profile = t.Profile()
+		keyEnv = t.KeyEnv
+	}
+
+	if flags.Changed("display-name") {
+		profile.DisplayName = addDisplayName
+	}
+	if flags.Changed("base-url") {
+		if err := config.ValidateProfileValue("base_url", addBaseURL); err != nil {
+			return fmt.Errorf("--base-url: %w", err)
+		}
+		profile.BaseURL = addBaseURL
+	}
+	if flags.Changed("api-key") && flags.Changed("api-key-env") {
+		return fmt.Errorf("--api-key and --api-key-env are both set; keep only one")
+	}
+	if flags.Changed("api-key") {
+		profile.APIKey = config.StringList{addAPIKey}
+		keyEnv = ""
+	}
+	if flags.Changed("api-key-env") {
+		if err := config.ValidateEnvName(addAPIKeyEnv); err != nil {
+			return fmt.Errorf("--api-key-env: %w", err)
+		}
+		profile.APIKey = config.StringList{"${" + addAPIKeyEnv + "}"}
+		keyEnv = addAPIKeyEnv
+	}
+	if flags.Changed("timeout") {
+		if err := config.ValidateProfileValue("timeout_ms", addTimeout); err != nil {
+			return fmt.Errorf("--timeout: %w", err)
+		}
+		profile.TimeoutMS = addTimeout
+	}
+	for _, m := range addModels {
+		slot, model, ok := strings.Cut(m, "=")
+		if !ok || model == "" {
+			return fmt.Errorf("invalid --model %q, expected <slot>=<model>", m)
+		}
+		if err := config.ValidateModelSlot(slot); err != nil {
+			return err
+		}
+		profile.Models[slot] = model
+	}
+
+	if err := saveNewProfile(profileName, profile); err != nil {
+		return err
+	}
+
+	if addFrom != "" {
+		fmt.Printf("%s Profile '%s' added from the %s template.\n", green("OK"), profileName, cyan(addFrom))
+	} else {
+		fmt.Printf("%s Profile '%s' added successfully.\n", green("OK"), profileName)
+	}
+	if keyEnv != "" {
+		if _, ok := os.LookupEnv(keyEnv); !ok {
+			fmt.Printf("Set %s to the API key before using it.\n", cyan(keyEnv))
+		}
+	}
+	fmt.Printf("Run %s to start using it.\n", cyan(fmt.Sprintf("cc-portkey use %s", profileName)))
+
+	return nil
+}
+
+// saveNewProfile adds a profile to the config, creating the config file if
+// needed. The existence check is repeated under the lock in case another
+// process added the same profile in the meantime.
+func saveNewProfile(name string, profile config.Profile) error {
+	if !config.Exists() {
+		cfg := &config.Config{Profiles: map[string]config.Profile{name: profile}}
+		if err := config.Save(cfg); err != nil {
+			return fmt.Errorf("failed to save config: %w", err)
+		}
+		return nil
+	}
+
+	return config.Update(func(cfg *config.Config) error {
+		if _, exists := cfg.Profiles[name]; exists {
+			return fmt.Errorf("profile '%s' already exists. Use 'cc-portkey edit' to modify it", name)
+		}
+		if cfg.Profiles == nil {
+			cfg.Profiles = make(map[string]config.Profile)
+		}
+		cfg.Profiles[name] = profile
+		return nil
+	})
+}
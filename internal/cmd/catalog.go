@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/nanmi/cc-portkey/internal/catalog"
+	"github.com/nanmi/cc-portkey/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var catalogCmd = &cobra.Command{
+	Use:   "catalog",
+	Short: "Browse provider templates for new profiles",
+	Long: `Browse the provider templates that 'cc-portkey add <name> --from <provider>'
+creates profiles from.
+
+The built-in catalog ships with cc-portkey. Add or override providers in
+~/.cc-portkey/catalog.json, which uses the same format:
+
+  {
+    "version": 1,
+    "providers": {
+      "my-proxy": {
+        "display_name": "My Proxy",
+        "base_url": "https://proxy.example.com/anthropic",
+        "key_env": "MY_PROXY_API_KEY",
+        "timeout_ms": 600000,
+        "models": { "default": "my-model" }
+      }
+    }
+  }`,
+}
+
+var catalogListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List providers in the catalog",
+	Args:    cobra.NoArgs,
+	RunE:    runCatalogList,
+}
+
+var catalogShowCmd = &cobra.Command{
+	Use:   "show <provider>",
+	Short: "Show a provider template",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runCatalogShow,
+}
+
+func init() {
+	catalogCmd.AddCommand(catalogListCmd, catalogShowCmd)
+	rootCmd.AddCommand(catalogCmd)
+}
+
+func runCatalogList(cmd *cobra.Command, args []string) error {
+	c, err := catalog.Load()
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(bold("Providers:"))
+	fmt.Println()
+	for _, name := range c.Names() {
+		t := c[name]
+		line := fmt.Sprintf("  %-12s  %-18s  %s", name, t.DisplayName, t.BaseURL)
+		if t.Source != catalog.SourceBuiltIn {
+			line += "  " + cyan("[local]")
+		}
+		fmt.Println(line)
+	}
+
+	fmt.Println()
+	fmt.Printf("Use %s to create a profile.\n", cyan("cc-portkey add <name> --from <provider>"))
+	return nil
+}
+
+func runCatalogShow(cmd *cobra.Command, args []string) error {
+	c, err := catalog.Load()
+	if err != nil {
+		return err
+	}
+	t, err := c.Get(args[0])
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s %s\n", bold("Provider:"), cyan(args[0]))
+	fmt.Println()
+	fmt.Printf("  Display Name:  %s\n", t.DisplayName)
+	fmt.Printf("  Base URL:      %s\n", t.BaseURL)
+	if key := t.KeyRef(); key != "" {
+		fmt.Printf("  API Key:       %s\n", key)
+	}
+	if t.AuthType != "" {
+		fmt.Printf("  Auth Type:     %s\n", t.AuthType)
+	}
+	if t.TimeoutMS > 0 {
+		fmt.Printf("  Timeout:       %dms\n", t.TimeoutMS)
+	}
+	if len(t.Models) > 0 {
+		fmt.Printf("  Models:\n")
+		for _, slot := range config.ModelSlots {
+			if model, ok := t.Models[slot.Key]; ok {
+				fmt.Printf("    %-12s %s\n", slot.Key+":", model)
+			}
+		}
+		for _, key := range sortedKeys(t.Models) {
+			if slot, _ := config.ModelSlotFor(key); slot.Key != key {
+				fmt.Printf("    %-12s %s\n", key+":", t.Models[key])
+			}
+		}
+	}
+	fmt.Printf("  Source:        %s\n", t.Source)
+
+	return nil
+}
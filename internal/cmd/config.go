@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/nanmi/cc-portkey/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Manage the configuration file",
+}
+
+var configMigrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Upgrade the config file to the current schema version",
+	Long: `Upgrade the config file to the schema version of this cc-portkey build.
+
+Older files are migrated automatically whenever they are loaded; this
+command lets you run or preview the migration explicitly. The original
+file is kept next to it as config.json.v<N>-<timestamp>.bak.`,
+	Args: cobra.NoArgs,
+	RunE: runConfigMigrate,
+}
+
+var migrateDryRun bool
+
+func init() {
+	configMigrateCmd.Flags().BoolVar(&migrateDryRun, "dry-run", false, "show what would change without writing")
+	configCmd.AddCommand(configMigrateCmd)
+	rootCmd.AddCommand(configCmd)
+}
+
+func runConfigMigrate(cmd *cobra.Command, args []string) error {
+	var plan *config.MigrationPlan
+	var err error
+	if migrateDryRun {
+		plan, err = config.PlanMigration()
+	} else {
+		plan, err = config.Migrate()
+	}
+	if err != nil {
+		return err
+	}
+
+	if !plan.Pending() {
+		fmt.Printf("%s Config is already at version %d.\n", green("OK"), plan.To)
+		return nil
+	}
+
+	fmt.Printf("Migrating %s from version %d to %d:\n", plan.Path, plan.From, plan.To)
+	for _, step := range plan.Steps {
+		fmt.Printf("  - %s\n", step)
+	}
+	fmt.Println()
+	printDiff(fmt.Sprintf("%s (v%d)", plan.Path, plan.From), fmt.Sprintf("%s (v%d)", plan.Path, plan.To),
+		string(plan.Before), string(plan.After))
+
+	fmt.Println()
+	if migrateDryRun {
+		fmt.Printf("Dry run, nothing was written. Run %s to apply.\n", cyan("cc-portkey config migrate"))
+		return nil
+	}
+	fmt.Printf("%s Migrated. The original was saved to %s\n", green("OK"), plan.Backup)
+
+	return nil
+}
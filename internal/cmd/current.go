@@ -3,6 +3,7 @@ package cmd
 import (
 	"fmt"
 
+	"github.com/nanmi/cc-portkey/internal/claude"
 	"github.com/nanmi/cc-portkey/internal/config"
 	"github.com/spf13/cobra"
 )
@@ -11,7 +12,7 @@ var currentCmd = &cobra.Command{
 	Use:    "current",
 	Short:  "Show current active profile",
 	Hidden: true, // 'list' shows current
-	RunE:  runCurrent,
+	RunE:   runCurrent,
 }
 
 func init() {
@@ -24,24 +25,43 @@ func runCurrent(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	if cfg.Current == "" {
+	active, err := claude.ActiveScope()
+	if err != nil {
+		return err
+	}
+
+	current := activeProfileName(cfg, active)
+	if current == "" {
 		fmt.Println("No profile is currently active.")
 		fmt.Printf("Run %s to switch to a profile.\n", cyan("cc-portkey use <profile>"))
 		return nil
 	}
 
-	profile, ok := cfg.Profiles[cfg.Current]
-	if !ok {
-		fmt.Printf("%s Current profile '%s' not found in config.\n", yellow("Warning:"), cfg.Current)
+	if _, ok := cfg.Profiles[current]; !ok {
+		fmt.Printf("%s Current profile '%s' not found in config.\n", yellow("Warning:"), current)
 		return nil
 	}
+	profile, err := cfg.Profile(current)
+	if err != nil {
+		return err
+	}
 
 	displayName := profile.DisplayName
 	if displayName == "" {
-		displayName = cfg.Current
+		displayName = current
 	}
 
-	fmt.Printf("%s (%s)\n", cyan(cfg.Current), displayName)
+	fmt.Printf("%s (%s)  [scope: %s, %s]\n", cyan(current), displayName, active.Scope, active.Path)
 
 	return nil
 }
+
+// activeProfileName returns the profile in effect for the working directory.
+// Project and local scopes use the manifest; the user scope falls back to
+// the config's current profile for settings written before the manifest existed.
+func activeProfileName(cfg *config.Config, active *claude.Active) string {
+	if active.Scope == claude.ScopeUser && active.Profile == "" {
+		return cfg.Current
+	}
+	return active.Profile
+}
@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around each change
+const diffContext = 2
+
+// diffLine is one line of a line-based diff
+type diffLine struct {
+	op   byte // ' ', '-' or '+'
+	text string
+}
+
+// lineDiff computes a line-based diff from a to b using the longest common subsequence
+func lineDiff(a, b string) []diffLine {
+	x := strings.Split(strings.TrimSuffix(a, "\n"), "\n")
+	y := strings.Split(strings.TrimSuffix(b, "\n"), "\n")
+	if a == "" {
+		x = nil
+	}
+	if b == "" {
+		y = nil
+	}
+
+	// lcs[i][j] is the LCS length of x[i:] and y[j:]
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var lines []diffLine
+	i, j := 0, 0
+	for i < len(x) && j < len(y) {
+		switch {
+		case x[i] == y[j]:
+			lines = append(lines, diffLine{' ', x[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			lines = append(lines, diffLine{'-', x[i]})
+			i++
+		default:
+			lines = append(lines, diffLine{'+', y[j]})
+			j++
+		}
+	}
+	for ; i < len(x); i++ {
+		lines = append(lines, diffLine{'-', x[i]})
+	}
+	for ; j < len(y); j++ {
+		lines = append(lines, diffLine{'+', y[j]})
+	}
+
+	return lines
+}
+
+// printDiff prints the changes between a and b with a little context.
+// It returns false if the two texts are identical.
+func printDiff(aName, bName, a, b string) bool {
+	lines := lineDiff(a, b)
+
+	// Mark which lines are close enough to a change to be shown
+	show := make([]bool, len(lines))
+	changed := false
+	for i, line := range lines {
+		if line.op == ' ' {
+			continue
+		}
+		changed = true
+		for k := i - diffContext; k <= i+diffContext; k++ {
+			if k >= 0 && k < len(lines) {
+				show[k] = true
+			}
+		}
+	}
+	if !changed {
+		return false
+	}
+
+	fmt.Println(red("--- " + aName))
+	fmt.Println(green("+++ " + bName))
+	skipped := false
+	for i, line := range lines {
+		if !show[i] {
+			skipped = true
+			continue
+		}
+		if skipped {
+			fmt.Println(cyan("..."))
+			skipped = false
+		}
+		switch line.op {
+		case '-':
+			fmt.Println(red("-" + line.text))
+		case '+':
+			fmt.Println(green("+" + line.text))
+		default:
+			fmt.Println(" " + line.text)
+		}
+	}
+
+	return true
+}
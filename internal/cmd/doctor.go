@@ -0,0 +1,649 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/nanmi/cc-portkey/internal/claude"
+	"github.com/nanmi/cc-portkey/internal/config"
+	"github.com/nanmi/cc-portkey/internal/keys"
+	"github.com/nanmi/cc-portkey/internal/secret"
+	"github.com/spf13/cobra"
+)
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Diagnose the cc-portkey and Claude Code setup",
+	Long: `Check the whole setup for common reasons Claude Code talks to the wrong
+provider: unresolved ${VAR} references, empty API keys, malformed base URLs,
+unknown model slots, broken shortcut symlinks, a missing claude binary,
+ANTHROPIC_* variables in the shell and settings that drifted from the
+current profile.
+
+Exits with a non-zero status if any errors are found.`,
+	Args: cobra.NoArgs,
+	Run:  runDoctor,
+}
+
+var doctorJSON bool
+
+func init() {
+	doctorCmd.Flags().BoolVar(&doctorJSON, "json", false, "output results as JSON")
+	rootCmd.AddCommand(doctorCmd)
+}
+
+// checkStatus is the outcome of a single doctor check
+type checkStatus string
+
+const (
+	statusOK    checkStatus = "ok"
+	statusWarn  checkStatus = "warn"
+	statusError checkStatus = "error"
+)
+
+// checkResult is one line of the doctor report
+type checkResult struct {
+	Check   string      `json:"check"`
+	Status  checkStatus `json:"status"`
+	Message string      `json:"message"`
+}
+
+// doctorReport collects check results
+type doctorReport struct {
+	Checks   []checkResult `json:"checks"`
+	Errors   int           `json:"errors"`
+	Warnings int           `json:"warnings"`
+}
+
+func (r *doctorReport) add(check string, status checkStatus, format string, args ...interface{}) {
+	r.Checks = append(r.Checks, checkResult{Check: check, Status: status, Message: fmt.Sprintf(format, args...)})
+	switch status {
+	case statusError:
+		r.Errors++
+	case statusWarn:
+		r.Warnings++
+	}
+}
+
+func runDoctor(cmd *cobra.Command, args []string) {
+	report := &doctorReport{}
+
+	cfg, err := config.Load()
+	if err != nil {
+		report.add("config", statusError, "%v", err)
+	} else {
+		path, _ := config.ConfigPath()
+		report.add("config", statusOK, "loaded %s (%d profiles)", path, len(cfg.Profiles))
+	}
+
+	var active *claude.Active
+	current := ""
+	if cfg != nil {
+		active, err = claude.ActiveScope()
+		if err != nil {
+			report.add("settings", statusError, "%v", err)
+		} else {
+			current = activeProfileName(cfg, active)
+		}
+		checkProfiles(report, cfg, current)
+	}
+
+	checkClaudeBinary(report)
+	if cfg != nil {
+		checkSymlinks(report, cfg)
+	}
+
+	var settingsEnv map[string]interface{}
+	if active != nil {
+		settings, err := claude.Load(active.Scope)
+		if err != nil {
+			report.add("settings", statusError, "%v", err)
+		} else {
+			report.add("settings", statusOK, "%s settings at %s", active.Scope, active.Path)
+			settingsEnv, _ = settings["env"].(map[string]interface{})
+			checkDrift(report, cfg, current, active, settings)
+		}
+	}
+
+	checkShellEnv(report, settingsEnv)
+
+	if doctorJSON {
+		data, _ := json.MarshalIndent(report, "", "  ")
+		fmt.Println(string(data))
+	} else {
+		printDoctorReport(report)
+	}
+
+	if report.Errors > 0 {
+		os.Exit(1)
+	}
+}
+
+// checkProfiles validates every profile. Problems with the active profile
+// are errors; the same problems in profiles not in use are only warnings.
+func checkProfiles(report *doctorReport, cfg *config.Config, current string) {
+	names := make([]string, 0, len(cfg.Profiles))
+	for name := range cfg.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		check := "profile " + name
+		severity := statusWarn
+		if name == current {
+			severity = statusError
+		}
+		profile, err := cfg.Profile(name)
+		if err != nil {
+			report.add(check, severity, "%v", err)
+			continue
+		}
+		problems := 0
+
+		if profile.IsSubscription() {
+			if ignored := apiFields(&profile); len(ignored) > 0 {
+				report.add(check, statusWarn, "%s ignored: the profile uses the %s", strings.Join(ignored, ", "), config.SubscriptionLabel)
+				problems++
+			}
+		}
+
+		for _, warning := range profile.ModelWarnings() {
+			report.add(check, statusWarn, "%s", warning)
+			problems++
+		}
+
+		if cfg.KeyHelper && len(profile.Headers) > 0 && !profile.IsSubscription() {
+			report.add(check, statusWarn, "headers not sent: key_helper keeps them out of settings files")
+			problems++
+		}
+
+		lookup, err := profile.Lookup()
+		if err != nil {
+			report.add(check, severity, "%v", err)
+			problems++
+			lookup = os.LookupEnv
+		}
+
+		for _, field := range referenceFields(&profile) {
+			missing, err := config.UnresolvedEnvVars(field.value, lookup)
+			if err != nil {
+				report.add(check, severity, "%s: %v", field.name, err)
+				problems++
+			}
+			if len(missing) > 0 {
+				report.add(check, severity, "%s references unset or empty variable(s): %s", field.name, strings.Join(missing, ", "))
+				problems++
+			}
+			if missing := missingSecrets(field.value); len(missing) > 0 {
+				report.add(check, severity, "%s references missing secret(s): %s", field.name, strings.Join(missing, ", "))
+				problems++
+			}
+		}
+
+		commands := []struct{ name, value, command string }{
+			{"base_url", profile.BaseURL, profile.BaseURLCmd},
+			{"api_key", strings.Join(profile.APIKey, ""), profile.APIKeyCmd},
+		}
+		for _, c := range commands {
+			if c.command == "" || profile.IsSubscription() {
+				continue
+			}
+			if c.value != "" {
+				report.add(check, severity, "%s and %s_cmd are both set; keep only one", c.name, c.name)
+				problems++
+			}
+			if program := commandProgram(c.command); program != "" {
+				if _, err := exec.LookPath(program); err != nil {
+					report.add(check, severity, "%s_cmd runs %q, which is not in PATH", c.name, program)
+					problems++
+				}
+			}
+		}
+
+		// Expanding secrets would prompt for the passphrase, so values
+		// referencing them are left unchecked while the vault is locked
+		locked := usesSecrets(&profile) && !secret.Available()
+		if locked {
+			report.add(check, statusWarn, "uses secrets but the vault is locked; run 'cc-portkey secret unlock' to check them")
+			problems++
+		}
+		expandable := func(value string) bool {
+			return !locked || len(config.References(value, "secret")) == 0
+		}
+
+		if err := keys.ValidateStrategy(profile.KeyStrategy); err != nil {
+			report.add(check, severity, "%v", err)
+			problems++
+		}
+		if err := claude.ValidateAuth(&profile); err != nil {
+			report.add(check, severity, "%v", err)
+			problems++
+		}
+		if err := claude.ValidateEnv(&profile); err != nil {
+			report.add(check, severity, "%v", err)
+			problems++
+		}
+		if _, err := profile.SettingsOverlay(); err != nil {
+			report.add(check, severity, "%v", err)
+			problems++
+		}
+		for _, name := range headerNames(&profile) {
+			if err := config.ValidateHeaderName(name); err != nil {
+				report.add(check, severity, "headers: %v", err)
+				problems++
+			}
+		}
+
+		if profile.APIKeyCmd == "" && profile.SendsKey() {
+			for _, field := range keyFields(&profile) {
+				if !expandable(field.value) {
+					continue
+				}
+				if apiKey, err := config.ExpandWith(field.value, lookup); err == nil && apiKey == "" {
+					report.add(check, severity, "%s is empty", field.name)
+					problems++
+				}
+			}
+		}
+
+		if !profile.IsSubscription() && expandable(profile.BaseURL) {
+			if baseURL, err := config.ExpandWith(profile.BaseURL, lookup); err == nil && baseURL != "" {
+				if err := config.ValidateBaseURL(baseURL); err != nil {
+					report.add(check, statusError, "base_url %q is malformed: %v", baseURL, err)
+					problems++
+				}
+			}
+		}
+
+		if problems == 0 && profile.IsSubscription() {
+			report.add(check, statusOK, "uses the %s", config.SubscriptionLabel)
+		} else if problems == 0 {
+			report.add(check, statusOK, "looks good")
+		}
+	}
+}
+
+// apiFields returns the fields set on a profile that only API profiles use
+func apiFields(profile *config.Profile) []string {
+	var fields []string
+	set := []struct {
+		name string
+		ok   bool
+	}{
+		{"base_url", profile.BaseURL != ""},
+		{"base_url_cmd", profile.BaseURLCmd != ""},
+		{"api_key", strings.Join(profile.APIKey, "") != ""},
+		{"api_key_cmd", profile.APIKeyCmd != ""},
+		{"auth_type", profile.AuthType != ""},
+		{"headers", len(profile.Headers) > 0},
+	}
+	for _, field := range set {
+		if field.ok {
+			fields = append(fields, field.name)
+		}
+	}
+	return fields
+}
+
+// commandProgram returns the program a *_cmd shell command starts,
+// or "" when the command is too complex to tell
+func commandProgram(command string) string {
+	fields := strings.Fields(command)
+	if len(fields) == 0 || strings.ContainsAny(fields[0], "=$`'\"()") {
+		return ""
+	}
+	return fields[0]
+}
+
+// profileField is a profile value that may hold references
+type profileField struct {
+	name, value string
+}
+
+// keyFields returns a profile's API keys, numbered when there are several
+func keyFields(profile *config.Profile) []profileField {
+	if profile.KeyCount() == 0 {
+		return []profileField{{"api_key", ""}}
+	}
+	if profile.KeyCount() == 1 {
+		return []profileField{{"api_key", profile.APIKey[0]}}
+	}
+	fields := make([]profileField, profile.KeyCount())
+	for i, key := range profile.APIKey {
+		fields[i] = profileField{fmt.Sprintf("api_key #%d", i+1), key}
+	}
+	return fields
+}
+
+// referenceFields returns every profile value that is expanded when the
+// profile is applied
+func referenceFields(profile *config.Profile) []profileField {
+	var fields []profileField
+	if !profile.IsSubscription() {
+		fields = append(fields, profileField{"base_url", profile.BaseURL})
+		if profile.SendsKey() {
+			fields = append(fields, keyFields(profile)...)
+		}
+		for _, name := range headerNames(profile) {
+			fields = append(fields, profileField{"headers." + name, profile.Headers[name]})
+		}
+	}
+	for _, name := range envNames(profile) {
+		fields = append(fields, profileField{"env." + name, profile.Env[name]})
+	}
+	return fields
+}
+
+// usesSecrets reports whether a profile references the secret vault
+func usesSecrets(profile *config.Profile) bool {
+	for _, field := range referenceFields(profile) {
+		if len(config.References(field.value, "secret")) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// missingSecrets returns the ${secret:NAME} references in s that the vault
+// doesn't hold. References can only be checked while the vault is unlocked.
+func missingSecrets(s string) []string {
+	names := config.References(s, "secret")
+	if len(names) == 0 {
+		return nil
+	}
+	if !secret.VaultExists() {
+		return names
+	}
+	if !secret.Available() {
+		return nil
+	}
+
+	v, err := secret.Unlock()
+	if err != nil {
+		return names
+	}
+	var missing []string
+	for _, name := range names {
+		if _, ok := v.Get(name); !ok {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
+
+// checkClaudeBinary makes sure Claude Code is installed
+func checkClaudeBinary(report *doctorReport) {
+	path, err := exec.LookPath("claude")
+	if err != nil {
+		report.add("claude", statusError, "claude command not found in PATH. Is Claude Code CLI installed?")
+		return
+	}
+	report.add("claude", statusOK, "found %s", path)
+}
+
+// checkSymlinks verifies the aliases and their symlinks in the default link directory
+func checkSymlinks(report *doctorReport, cfg *config.Config) {
+	linkDir := GetDefaultLinkDir()
+
+	execPath, err := os.Executable()
+	if err == nil {
+		execPath, err = filepath.EvalSymlinks(execPath)
+	}
+	if err != nil {
+		report.add("aliases", statusWarn, "cannot determine cc-portkey executable path: %v", err)
+		return
+	}
+
+	for _, alias := range aliasNames(cfg) {
+		check := "alias " + alias
+		if err := config.ValidateAliasName(alias); err != nil {
+			report.add(check, statusWarn, "%v", err)
+			continue
+		}
+		profile := cfg.Aliases[alias].Profile
+		if _, ok := cfg.Profiles[profile]; !ok {
+			report.add(check, statusWarn, "launches profile '%s', which does not exist", profile)
+			continue
+		}
+		linkPath := aliasPath(linkDir, alias)
+
+		fi, err := os.Lstat(linkPath)
+		if err != nil {
+			if shadowed := aliasConflict(alias, linkPath, execPath); shadowed != "" {
+				report.add(check, statusWarn, "not linked: it would shadow %s. Rename the alias or run 'cc-portkey link --force'", shadowed)
+			} else {
+				report.add(check, statusWarn, "%s is missing. Run 'cc-portkey link'", linkPath)
+			}
+			continue
+		}
+		if fi.Mode()&os.ModeSymlink == 0 {
+			report.add(check, statusWarn, "%s is not a symlink", linkPath)
+			continue
+		}
+
+		target, _ := os.Readlink(linkPath)
+		switch {
+		case target == execPath:
+			report.add(check, statusOK, "%s -> %s", linkPath, target)
+		case !fileExists(target):
+			report.add(check, statusWarn, "%s is stale: %s no longer exists. Run 'cc-portkey link'", linkPath, target)
+		default:
+			report.add(check, statusWarn, "%s points elsewhere: %s", linkPath, target)
+		}
+	}
+
+	links, err := loadLinkRecord()
+	if err != nil {
+		report.add("aliases", statusWarn, "%v", err)
+	}
+	for _, alias := range staleAliases(cfg, links, linkDir, execPath) {
+		report.add("alias "+alias, statusWarn, "%s is left from a removed alias. Run 'cc-portkey link' to prune it", aliasPath(linkDir, alias))
+	}
+
+	if !isInPath(linkDir) {
+		report.add("aliases", statusWarn, "%s is not in your PATH", linkDir)
+	}
+}
+
+// fileExists reports whether path exists
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// checkDrift compares the active settings file with what the current profile would write
+func checkDrift(report *doctorReport, cfg *config.Config, current string, active *claude.Active, settings claude.Settings) {
+	if current == "" {
+		report.add("drift", statusOK, "no profile applied")
+		return
+	}
+	if _, ok := cfg.Profiles[current]; !ok {
+		report.add("drift", statusError, "current profile '%s' not found in config", current)
+		return
+	}
+	profile, err := cfg.Profile(current)
+	if err != nil {
+		report.add("drift", statusError, "%v", err)
+		return
+	}
+
+	manifest, err := claude.LoadManifest()
+	if err != nil {
+		report.add("drift", statusError, "%v", err)
+		return
+	}
+
+	if usesSecrets(&profile) && !secret.Available() {
+		report.add("drift", statusWarn, "skipped: profile '%s' uses secrets and the vault is locked", current)
+		return
+	}
+	profile = profile.WithKey(keys.Current(current, &profile))
+	profile, unchecked := withoutCommands(profile)
+	profileEnv, err := claude.ProfileEnv(&profile)
+	if err != nil {
+		report.add("drift", statusError, "profile '%s': %v", current, err)
+		return
+	}
+	overlay, err := profile.SettingsOverlay()
+	if err != nil {
+		report.add("drift", statusError, "profile '%s': %v", current, err)
+		return
+	}
+	applied := settingsFor(cfg, current, profileEnv, overlay)
+	expected := applied.Env
+	env, _ := settings["env"].(map[string]interface{})
+
+	envKeys := make(map[string]bool)
+	for key := range expected {
+		envKeys[key] = true
+	}
+	for _, key := range manifest.OwnedEnvKeys(active.Path) {
+		envKeys[key] = true
+	}
+
+	var drifted []string
+	for key := range envKeys {
+		if unchecked[key] {
+			continue
+		}
+		want, wantOK := expected[key]
+		got, gotOK := env[key]
+		if wantOK != gotOK || (gotOK && fmt.Sprint(got) != want) {
+			drifted = append(drifted, key)
+		}
+	}
+
+	// Other settings must hold what the profile sets, or their original
+	// value once no profile sets them
+	paths := make(map[string]bool)
+	for p := range applied.Settings {
+		paths[p] = true
+	}
+	original := manifest.Files[active.Path].Paths
+	for p := range original {
+		paths[p] = true
+	}
+	for p := range paths {
+		want, ok := applied.Settings[p]
+		if !ok {
+			json.Unmarshal(original[p], &want)
+		}
+		got, _ := settingAt(settings, p)
+		if !sameJSON(want, got) {
+			drifted = append(drifted, p)
+		}
+	}
+	sort.Strings(drifted)
+
+	if len(drifted) > 0 {
+		report.add("drift", statusError, "%s differs from profile '%s' in: %s. Run 'cc-portkey use %s'",
+			active.Path, current, strings.Join(drifted, ", "), current)
+		return
+	}
+	if len(unchecked) > 0 {
+		report.add("drift", statusOK, "%s matches profile '%s' (values from *_cmd not checked)", active.Path, current)
+		return
+	}
+	report.add("drift", statusOK, "%s matches profile '%s'", active.Path, current)
+}
+
+// withoutCommands replaces the profile's base_url_cmd and api_key_cmd with
+// placeholders, since doctor must not run commands that may prompt or hang.
+// It returns the env keys derived from them, which can't be compared.
+func withoutCommands(profile config.Profile) (config.Profile, map[string]bool) {
+	unchecked := make(map[string]bool)
+	if profile.BaseURLCmd != "" {
+		profile.BaseURL, profile.BaseURLCmd = "https://base-url-cmd.invalid", ""
+		unchecked["ANTHROPIC_BASE_URL"] = true
+		unchecked["CLAUDE_CODE_DISABLE_NONESSENTIAL_TRAFFIC"] = true
+	}
+	if profile.APIKeyCmd != "" {
+		profile.APIKey, profile.APIKeyCmd = config.StringList{"api-key-cmd"}, ""
+		unchecked["ANTHROPIC_AUTH_TOKEN"] = true
+		unchecked["ANTHROPIC_API_KEY"] = true
+	}
+	return profile, unchecked
+}
+
+// settingAt returns the value at a dotted settings path
+func settingAt(settings map[string]interface{}, p string) (interface{}, bool) {
+	var value interface{} = settings
+	for _, key := range strings.Split(p, ".") {
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if value, ok = obj[key]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// sameJSON reports whether a and b encode to the same JSON
+func sameJSON(a, b interface{}) bool {
+	aj, errA := json.Marshal(a)
+	bj, errB := json.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(aj, bj)
+}
+
+// checkShellEnv reports ANTHROPIC_* variables exported in the shell.
+// Claude Code uses them whenever settings.json doesn't set the same key.
+func checkShellEnv(report *doctorReport, settingsEnv map[string]interface{}) {
+	var names []string
+	for _, kv := range os.Environ() {
+		name, _, _ := strings.Cut(kv, "=")
+		if strings.HasPrefix(name, "ANTHROPIC_") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	found := false
+	for _, name := range names {
+		if _, ok := settingsEnv[name]; ok {
+			continue
+		}
+		report.add("shell env", statusWarn, "%s is set in your shell and is not overridden by settings.json", name)
+		found = true
+	}
+	if !found {
+		report.add("shell env", statusOK, "no ANTHROPIC_* variables override settings.json")
+	}
+}
+
+// printDoctorReport prints the report for humans
+func printDoctorReport(report *doctorReport) {
+	fmt.Println(bold("cc-portkey doctor"))
+	fmt.Println()
+
+	for _, c := range report.Checks {
+		var label string
+		switch c.Status {
+		case statusOK:
+			label = green("OK   ")
+		case statusWarn:
+			label = yellow("WARN ")
+		default:
+			label = red("ERROR")
+		}
+		fmt.Printf("  %s  %-18s %s\n", label, c.Check, c.Message)
+	}
+
+	fmt.Println()
+	switch {
+	case report.Errors > 0:
+		fmt.Printf("%s %d error(s), %d warning(s)\n", red("Found"), report.Errors, report.Warnings)
+	case report.Warnings > 0:
+		fmt.Printf("%s No errors, %d warning(s)\n", green("OK"), report.Warnings)
+	default:
+		fmt.Printf("%s Everything looks good\n", green("OK"))
+	}
+}
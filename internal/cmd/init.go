@@ -13,7 +13,8 @@ var initCmd = &cobra.Command{
 	Long: `Initialize the cc-portkey configuration file with default profiles
 for common providers (Claude, DeepSeek, GLM, MiniMax).
 
-The configuration will be created at ~/.cc-portkey/config.json`,
+The configuration will be created at ~/.cc-portkey/config.json,
+or at the path given by --config / $CC_PORTKEY_CONFIG.`,
 	RunE: runInit,
 }
 
@@ -39,8 +40,13 @@ func runInit(cmd *cobra.Command, args []string) error {
 		fmt.Println("Creating shortcut commands...")
 	}
 
+	// Keep the schema referenced by "$schema" in sync with this build
+	if _, err := config.WriteSchema(); err != nil {
+		fmt.Printf("%s Failed to write config schema: %v\n", yellow("Warning:"), err)
+	}
+
 	// Create/update symlinks for all aliases
-	if err := CreateSymlinks("", true); err != nil {
+	if err := CreateSymlinks("", true, false); err != nil {
 		fmt.Printf("%s Failed to create symlinks: %v\n", yellow("Warning:"), err)
 		fmt.Println("You can try again later with: cc-portkey link")
 	}
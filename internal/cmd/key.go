@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/nanmi/cc-portkey/internal/config"
+	"github.com/nanmi/cc-portkey/internal/keys"
+	"github.com/spf13/cobra"
+)
+
+var keyCmd = &cobra.Command{
+	Use:   "key",
+	Short: "Manage the API key pool of a profile",
+	Long: `Profiles can list several API keys under api_key and rotate through them
+according to key_strategy: round_robin (default), random or sticky_per_project.
+
+Keys are numbered from 1, in the order they appear in the config; these are
+the numbers 'key list' prints and 'key mark-exhausted' takes. keys.json,
+which holds the rotation state, refers to keys by a hash rather than their
+number, so reordering or removing keys doesn't move that state.`,
+}
+
+var keyListCmd = &cobra.Command{
+	Use:   "list <profile>",
+	Short: "Show a profile's keys, the one in use and any in cooldown",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runKeyList,
+}
+
+var keyMarkExhaustedCmd = &cobra.Command{
+	Use:   "mark-exhausted <profile> <number>",
+	Short: "Skip a key until its cooldown expires",
+	Long: `Skip a key until its cooldown expires.
+
+<number> is the key's number as shown by 'cc-portkey key list', counting
+from 1 in the order the keys appear in api_key.`,
+	Args: cobra.ExactArgs(2),
+	RunE: runKeyMarkExhausted,
+}
+
+var keyCooldown time.Duration
+
+func init() {
+	keyMarkExhaustedCmd.Flags().DurationVar(&keyCooldown, "cooldown", time.Hour, "how long to skip the key")
+	keyCmd.AddCommand(keyListCmd, keyMarkExhaustedCmd)
+	rootCmd.AddCommand(keyCmd)
+}
+
+func runKeyList(cmd *cobra.Command, args []string) error {
+	profile, err := loadProfile(args[0])
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s %s (numbered from 1)\n", bold("Keys of"), cyan(args[0]))
+	printKeys(args[0], profile)
+	return nil
+}
+
+func runKeyMarkExhausted(cmd *cobra.Command, args []string) error {
+	profileName := args[0]
+	profile, err := loadProfile(profileName)
+	if err != nil {
+		return err
+	}
+
+	number, err := strconv.Atoi(args[1])
+	if err != nil {
+		return fmt.Errorf("invalid key number %q", args[1])
+	}
+	if err := keys.MarkExhausted(profileName, profile, number-1, keyCooldown); err != nil {
+		return err
+	}
+
+	fmt.Printf("%s Key #%d of '%s' skipped until %s\n", green("OK"), number, profileName,
+		time.Now().Add(keyCooldown).Format("15:04:05"))
+	if keys.Current(profileName, profile) == number-1 {
+		fmt.Printf("It is still in use; run %s to move to the next key.\n", cyan("cc-portkey use "+profileName))
+	}
+	return nil
+}
+
+// loadProfile loads the config and returns the named profile
+func loadProfile(name string) (*config.Profile, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+	profile, err := cfg.Profile(name)
+	if err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
+
+// printKeys lists a profile's keys masked, marking the one in use
+func printKeys(profileName string, profile *config.Profile) {
+	current := keys.Current(profileName, profile)
+	exhausted := keys.ExhaustedUntil(profileName, profile)
+
+	for i, key := range profile.APIKey {
+		line := fmt.Sprintf("    #%d  %s", i+1, config.MaskAPIKey(key))
+		if until, ok := exhausted[i]; ok {
+			line += "  " + yellow("exhausted until "+until.Local().Format("15:04:05"))
+		}
+		if i == current {
+			line += "  " + green("[in use]")
+		}
+		fmt.Println(line)
+	}
+}
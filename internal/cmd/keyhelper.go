@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/nanmi/cc-portkey/internal/claude"
+	"github.com/nanmi/cc-portkey/internal/config"
+	"github.com/nanmi/cc-portkey/internal/keys"
+	"github.com/spf13/cobra"
+)
+
+// keyHelperCmd is what Claude Code runs through apiKeyHelper when the
+// config enables key_helper. It prints the profile's key to stdout.
+var keyHelperCmd = &cobra.Command{
+	Use:    "key-helper <profile>",
+	Short:  "Print a profile's API key for Claude Code's apiKeyHelper",
+	Hidden: true,
+	Args:   cobra.ExactArgs(1),
+	Run:    runKeyHelper,
+}
+
+func init() {
+	rootCmd.AddCommand(keyHelperCmd)
+}
+
+func runKeyHelper(cmd *cobra.Command, args []string) {
+	// Claude Code reads the key from stdout, so errors go to stderr only
+	key, err := helperKey(args[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cc-portkey key-helper: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println(key)
+}
+
+// helperKey resolves the API key of the named profile
+func helperKey(profileName string) (string, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return "", err
+	}
+	profile, err := cfg.Profile(profileName)
+	if err != nil {
+		return "", err
+	}
+	// Use the key chosen at the last switch; rotating per request would
+	// defeat sticky_per_project
+	profile = profile.WithKey(keys.Current(profileName, &profile))
+	resolved, err := profile.Resolve()
+	if err != nil {
+		return "", fmt.Errorf("profile '%s': %w", profileName, err)
+	}
+	if !profile.SendsKey() {
+		return "", fmt.Errorf("profile '%s' uses auth_type %s and sends no API key", profileName, profile.AuthType)
+	}
+	if resolved.APIKey == "" {
+		return "", fmt.Errorf("profile '%s' has no API key", profileName)
+	}
+	return resolved.APIKey, nil
+}
+
+// keyHelperCommand returns the apiKeyHelper command line for a profile
+func keyHelperCommand(profileName string) string {
+	exe, err := os.Executable()
+	if err != nil {
+		exe = "cc-portkey"
+	}
+	args := []string{shellQuote(exe)}
+
+	// Pin a non-default config so the helper reads the same file
+	if cfgFile != "" || os.Getenv(config.ConfigEnvVar) != "" {
+		if path, err := config.ConfigPath(); err == nil {
+			args = append(args, "--config", shellQuote(path))
+		}
+	}
+
+	args = append(args, "key-helper", shellQuote(profileName))
+	return strings.Join(args, " ")
+}
+
+// shellQuote quotes s for the shell Claude Code runs apiKeyHelper with
+func shellQuote(s string) string {
+	if runtime.GOOS == "windows" {
+		if strings.ContainsAny(s, " \t&()^") {
+			return `"` + s + `"`
+		}
+		return s
+	}
+	if s != "" && !strings.ContainsAny(s, " \t\"'$`\\&|;<>()*?[]#~!{}") {
+		return s
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", `\$`, "`", "\\`").Replace(s) + `"`
+}
+
+// settingsFor returns what applying a profile writes into a settings file:
+// its env and its settings overlay. With key_helper the API key stays out
+// of the file and Claude Code asks cc-portkey for it instead, taking
+// precedence over an apiKeyHelper in the overlay. apiKeyHelper can only
+// supply the key, so custom headers, which may carry credentials too, are
+// left out as well; see withheldHeaders.
+func settingsFor(cfg *config.Config, profileName string, profileEnv map[string]string, overlay map[string]interface{}) *claude.Applied {
+	if !cfg.KeyHelper {
+		return &claude.Applied{Env: profileEnv, Settings: overlay}
+	}
+
+	_, bearer := profileEnv["ANTHROPIC_AUTH_TOKEN"]
+	_, xAPIKey := profileEnv["ANTHROPIC_API_KEY"]
+	sendsKey := bearer || xAPIKey
+
+	env := make(map[string]string, len(profileEnv))
+	for key, value := range profileEnv {
+		switch key {
+		case "ANTHROPIC_AUTH_TOKEN", "ANTHROPIC_API_KEY", "ANTHROPIC_CUSTOM_HEADERS":
+			continue
+		}
+		env[key] = value
+	}
+	if !sendsKey {
+		return &claude.Applied{Env: env, Settings: overlay}
+	}
+
+	settings := map[string]interface{}{"apiKeyHelper": keyHelperCommand(profileName)}
+	for p, value := range overlay {
+		if p != "apiKeyHelper" {
+			settings[p] = value
+		}
+	}
+	return &claude.Applied{Env: env, Settings: settings}
+}
+
+// withheldHeaders reports whether settingsFor left the profile's custom
+// headers out because key_helper is on
+func withheldHeaders(cfg *config.Config, profileEnv map[string]string) bool {
+	_, ok := profileEnv["ANTHROPIC_CUSTOM_HEADERS"]
+	return cfg.KeyHelper && ok
+}
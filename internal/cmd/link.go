@@ -1,27 +1,44 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"runtime"
+	"sort"
+	"strings"
 
 	"github.com/nanmi/cc-portkey/internal/config"
+	"github.com/nanmi/cc-portkey/internal/fileutil"
 	"github.com/spf13/cobra"
 )
 
+// linkRecordName is the file, next to the config, listing the symlinks
+// created for it, so link only prunes its own
+const linkRecordName = "links.json"
+
 var linkCmd = &cobra.Command{
 	Use:    "link [directory]",
-	Short:  "Create shortcut symlinks (ccc, ds, glm, mm)",
+	Short:  "Create shortcut symlinks for the aliases in the config",
 	Hidden: true, // 'init' does this automatically
 	Long: `Create symbolic links for quick profile switching.
 
-Creates symlinks in the specified directory (default ~/.local/bin/):
+Creates a symlink to cc-portkey in the specified directory (default ~/.local/bin/)
+for every entry in the config's "aliases" section, e.g. with the defaults:
   ccc -> cc-portkey (switches to claude)
   ds  -> cc-portkey (switches to deepseek)
   glm -> cc-portkey (switches to glm)
   mm  -> cc-portkey (switches to minimax)
 
+Symlinks this config created whose alias has since been removed are
+deleted; links made for other config files are left alone.
+
+An alias whose name is already a command on PATH, or whose link path holds
+some other file, is skipped so it can't shadow that command. Use --force to
+replace it anyway.
+
 After creating links, you can quickly switch profiles:
   $ ds    # switches to DeepSeek
   $ glm   # switches to GLM`,
@@ -29,7 +46,10 @@ After creating links, you can quickly switch profiles:
 	RunE: runLink,
 }
 
+var linkForce bool
+
 func init() {
+	linkCmd.Flags().BoolVar(&linkForce, "force", false, "link aliases even if they shadow another command")
 	rootCmd.AddCommand(linkCmd)
 }
 
@@ -40,13 +60,20 @@ func runLink(cmd *cobra.Command, args []string) error {
 	} else {
 		targetDir = ""
 	}
-	return CreateSymlinks(targetDir, true)
+	return CreateSymlinks(targetDir, true, linkForce)
 }
 
-// CreateSymlinks creates symlinks for all aliases
+// CreateSymlinks creates symlinks for all aliases in the config and removes
+// the ones it created earlier for aliases that are gone
 // If targetDir is empty, uses ~/.local/bin/
 // If verbose is true, prints detailed output
-func CreateSymlinks(targetDir string, verbose bool) error {
+// If force is true, aliases replace commands they would shadow
+func CreateSymlinks(targetDir string, verbose, force bool) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+
 	// Determine target directory
 	if targetDir == "" {
 		home, err := os.UserHomeDir()
@@ -56,6 +83,12 @@ func CreateSymlinks(targetDir string, verbose bool) error {
 		targetDir = filepath.Join(home, ".local", "bin")
 	}
 
+	// Links are recorded by absolute path
+	targetDir, err = filepath.Abs(targetDir)
+	if err != nil {
+		return fmt.Errorf("failed to resolve directory %s: %w", targetDir, err)
+	}
+
 	// Create target directory if it doesn't exist
 	if err := os.MkdirAll(targetDir, 0755); err != nil {
 		return fmt.Errorf("failed to create directory %s: %w", targetDir, err)
@@ -71,16 +104,29 @@ func CreateSymlinks(targetDir string, verbose bool) error {
 		return fmt.Errorf("failed to resolve executable path: %w", err)
 	}
 
+	links, err := loadLinkRecord()
+	if err != nil {
+		return err
+	}
+
 	// Create symlinks for each alias
 	created := 0
 	skipped := 0
 
-	for alias := range config.AliasMapping {
-		linkPath := filepath.Join(targetDir, alias)
+	for _, alias := range aliasNames(cfg) {
+		if err := config.ValidateAliasName(alias); err != nil {
+			if verbose {
+				fmt.Printf("  %s -> %s (%v)\n", alias, red("ERROR"), err)
+			}
+			continue
+		}
+		linkPath := aliasPath(targetDir, alias)
 
-		// On Windows, add .exe extension
-		if runtime.GOOS == "windows" {
-			linkPath += ".exe"
+		if shadowed := aliasConflict(alias, linkPath, execPath); shadowed != "" && !force {
+			if verbose {
+				fmt.Printf("  %s -> %s (would shadow %s; use --force to replace it)\n", alias, yellow("SKIPPED"), shadowed)
+			}
+			continue
 		}
 
 		// Check if link already exists
@@ -118,9 +164,31 @@ func CreateSymlinks(targetDir string, verbose bool) error {
 		if verbose {
 			fmt.Printf("  %s -> created\n", green(alias))
 		}
+		links[linkPath] = true
 		created++
 	}
 
+	// Prune symlinks this config created for aliases since removed
+	pruned := 0
+	for _, alias := range staleAliases(cfg, links, targetDir, execPath) {
+		linkPath := aliasPath(targetDir, alias)
+		if err := os.Remove(linkPath); err != nil {
+			if verbose {
+				fmt.Printf("  %s -> %s (%v)\n", alias, red("ERROR"), err)
+			}
+			continue
+		}
+		if verbose {
+			fmt.Printf("  %s -> removed (no longer an alias)\n", alias)
+		}
+		delete(links, linkPath)
+		pruned++
+	}
+
+	if err := saveLinkRecord(links); err != nil {
+		return err
+	}
+
 	if verbose {
 		fmt.Println()
 		if created > 0 {
@@ -129,6 +197,9 @@ func CreateSymlinks(targetDir string, verbose bool) error {
 		if skipped > 0 {
 			fmt.Printf("   Skipped %d existing symlink(s)\n", skipped)
 		}
+		if pruned > 0 {
+			fmt.Printf("   Removed %d stale symlink(s)\n", pruned)
+		}
 
 		// Check if directory is in PATH
 		if !isInPath(targetDir) {
@@ -142,6 +213,144 @@ func CreateSymlinks(targetDir string, verbose bool) error {
 	return nil
 }
 
+// aliasNames returns the configured alias names in sorted order
+func aliasNames(cfg *config.Config) []string {
+	names := make([]string, 0, len(cfg.Aliases))
+	for name := range cfg.Aliases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// aliasPath returns where the symlink for alias lives in dir
+func aliasPath(dir, alias string) string {
+	linkPath := filepath.Join(dir, alias)
+	// On Windows, add .exe extension
+	if runtime.GOOS == "windows" {
+		linkPath += ".exe"
+	}
+	return linkPath
+}
+
+// linkedAliases returns the names of the symlinks in dir that point to
+// execPath, other than cc-portkey itself
+func linkedAliases(dir, execPath string) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, entry := range entries {
+		if entry.Type()&os.ModeSymlink == 0 {
+			continue
+		}
+		target, err := os.Readlink(filepath.Join(dir, entry.Name()))
+		if err != nil || target != execPath {
+			continue
+		}
+		name := strings.TrimSuffix(entry.Name(), ".exe")
+		if name != rootCmd.Name() {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// staleAliases returns the aliases whose links in dir were created for this
+// config and still point to execPath, but which the config no longer has
+func staleAliases(cfg *config.Config, links map[string]bool, dir, execPath string) []string {
+	var names []string
+	for _, alias := range linkedAliases(dir, execPath) {
+		if _, ok := cfg.Aliases[alias]; !ok && links[aliasPath(dir, alias)] {
+			names = append(names, alias)
+		}
+	}
+	return names
+}
+
+// aliasConflict returns the command or file a link for alias would shadow:
+// another executable found on PATH under that name, or something other than
+// a cc-portkey symlink at linkPath. Links whose target is gone are not
+// conflicts, so a moved cc-portkey can relink them.
+func aliasConflict(alias, linkPath, execPath string) string {
+	if found, err := exec.LookPath(alias); err == nil {
+		if resolved, err := filepath.EvalSymlinks(found); err == nil && resolved != execPath {
+			if abs, err := filepath.Abs(found); err != nil || abs != linkPath {
+				return found
+			}
+		}
+	}
+
+	fi, err := os.Lstat(linkPath)
+	if err != nil {
+		return ""
+	}
+	if fi.Mode()&os.ModeSymlink == 0 {
+		return linkPath
+	}
+	target, err := os.Readlink(linkPath)
+	if err == nil && target != execPath && fileExists(target) {
+		return linkPath
+	}
+	return ""
+}
+
+// linkRecordPath returns the file listing the symlinks created for this config
+func linkRecordPath() (string, error) {
+	dir, err := config.ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, linkRecordName), nil
+}
+
+// loadLinkRecord returns the paths of the symlinks created for this config
+func loadLinkRecord() (map[string]bool, error) {
+	links := make(map[string]bool)
+	path, err := linkRecordPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return links, nil
+		}
+		return nil, fmt.Errorf("failed to read link record: %w", err)
+	}
+	var paths []string
+	if err := json.Unmarshal(data, &paths); err != nil {
+		return nil, fmt.Errorf("failed to parse link record %s: %w", path, err)
+	}
+	for _, p := range paths {
+		links[p] = true
+	}
+	return links, nil
+}
+
+// saveLinkRecord writes the paths of the symlinks created for this config
+func saveLinkRecord(links map[string]bool) error {
+	path, err := linkRecordPath()
+	if err != nil {
+		return err
+	}
+	paths := make([]string, 0, len(links))
+	for p := range links {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	data, err := json.MarshalIndent(paths, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal link record: %w", err)
+	}
+	if err := fileutil.WriteFileAtomic(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to save link record: %w", err)
+	}
+	return nil
+}
+
 // GetDefaultLinkDir returns the default directory for symlinks
 func GetDefaultLinkDir() string {
 	home, err := os.UserHomeDir()
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"sort"
 
+	"github.com/nanmi/cc-portkey/internal/claude"
 	"github.com/nanmi/cc-portkey/internal/config"
 	"github.com/spf13/cobra"
 )
@@ -31,6 +32,12 @@ func runList(cmd *cobra.Command, args []string) error {
 		return nil
 	}
 
+	active, err := claude.ActiveScope()
+	if err != nil {
+		return err
+	}
+	current := activeProfileName(cfg, active)
+
 	// Sort profile names for consistent output
 	names := make([]string, 0, len(cfg.Profiles))
 	for name := range cfg.Profiles {
@@ -42,9 +49,14 @@ func runList(cmd *cobra.Command, args []string) error {
 	fmt.Println()
 
 	for _, name := range names {
-		profile := cfg.Profiles[name]
+		// Load rejects broken extends chains, so this only falls back to
+		// the profile as written should one slip through
+		profile, err := cfg.Profile(name)
+		if err != nil {
+			profile = cfg.Profiles[name]
+		}
 		marker := "  "
-		if name == cfg.Current {
+		if name == current {
 			marker = green("* ")
 		}
 
@@ -53,14 +65,23 @@ func runList(cmd *cobra.Command, args []string) error {
 			displayName = name
 		}
 
+		if profile.IsSubscription() {
+			displayName += "  " + cyan("("+config.SubscriptionLabel+")")
+		}
+
 		// Show current marker
-		if name == cfg.Current {
+		if name == current {
 			fmt.Printf("%s%-12s  %s  %s\n", marker, cyan(name), displayName, yellow("[current]"))
 		} else {
 			fmt.Printf("%s%-12s  %s\n", marker, name, displayName)
 		}
 	}
 
+	fmt.Println()
+	fmt.Printf("Active scope: %s (%s)\n", cyan(string(active.Scope)), active.Path)
+	if active.Scope != claude.ScopeUser && cfg.Current != "" && cfg.Current != current {
+		fmt.Printf("   User scope is %s, overridden in this project.\n", cfg.Current)
+	}
 	fmt.Println()
 	fmt.Printf("Use %s to switch profiles.\n", cyan("cc-portkey use <profile>"))
 
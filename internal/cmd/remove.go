@@ -2,6 +2,8 @@ package cmd
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/nanmi/cc-portkey/internal/config"
 	"github.com/spf13/cobra"
@@ -23,25 +25,38 @@ func init() {
 func runRemove(cmd *cobra.Command, args []string) error {
 	profileName := args[0]
 
-	cfg, err := config.Load()
-	if err != nil {
-		return err
-	}
+	wasCurrent := false
+	err := config.Update(func(cfg *config.Config) error {
+		if _, exists := cfg.Profiles[profileName]; !exists {
+			return fmt.Errorf("profile '%s' not found", profileName)
+		}
 
-	if _, exists := cfg.Profiles[profileName]; !exists {
-		return fmt.Errorf("profile '%s' not found", profileName)
-	}
+		var children []string
+		for name, profile := range cfg.Profiles {
+			if profile.Extends == profileName {
+				children = append(children, name)
+			}
+		}
+		if len(children) > 0 {
+			sort.Strings(children)
+			return fmt.Errorf("profile '%s' is extended by %s; change their extends first", profileName, strings.Join(children, ", "))
+		}
 
-	delete(cfg.Profiles, profileName)
+		delete(cfg.Profiles, profileName)
 
-	// If we deleted the current profile, clear current
-	if cfg.Current == profileName {
-		cfg.Current = ""
-		fmt.Printf("%s Profile '%s' was the current profile. No profile is now active.\n", yellow("Note:"), profileName)
+		// If we deleted the current profile, clear current
+		if cfg.Current == profileName {
+			cfg.Current = ""
+			wasCurrent = true
+		}
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
-	if err := config.Save(cfg); err != nil {
-		return fmt.Errorf("failed to save config: %w", err)
+	if wasCurrent {
+		fmt.Printf("%s Profile '%s' was the current profile. No profile is now active.\n", yellow("Note:"), profileName)
 	}
 
 	fmt.Printf("%s Profile '%s' removed.\n", green("OK"), profileName)
@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nanmi/cc-portkey/internal/claude"
+	"github.com/nanmi/cc-portkey/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var resetCmd = &cobra.Command{
+	Use:   "reset",
+	Short: "Remove cc-portkey settings and return to official Claude Code",
+	Long: `Remove every env key cc-portkey wrote to Claude's settings, so Claude Code
+goes back to its vanilla setup (official API and your own login).
+
+Keys you set by hand are left alone. Use --scope to reset a project or
+local settings file instead of ~/.claude/settings.json.`,
+	Args: cobra.NoArgs,
+	RunE: runReset,
+}
+
+var resetScope string
+
+func init() {
+	resetCmd.Flags().StringVarP(&resetScope, "scope", "s", "user", "settings scope to reset: user, project or local")
+	rootCmd.AddCommand(resetCmd)
+}
+
+func runReset(cmd *cobra.Command, args []string) error {
+	scope, err := claude.ParseScope(resetScope)
+	if err != nil {
+		return err
+	}
+
+	removed, err := claude.Reset(scope)
+	if err != nil {
+		return fmt.Errorf("failed to reset settings: %w", err)
+	}
+
+	// The config's current profile tracks the user scope only
+	if scope == claude.ScopeUser && config.Exists() {
+		err := config.Update(func(cfg *config.Config) error {
+			cfg.Current = ""
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to update config: %w", err)
+		}
+	}
+
+	path, _ := claude.SettingsPathFor(scope)
+	if len(removed) == 0 {
+		fmt.Printf("%s No cc-portkey settings found in %s\n", green("OK"), path)
+		return nil
+	}
+
+	fmt.Printf("%s Reset %d key(s) in %s\n", green("OK"), len(removed), path)
+	fmt.Printf("  %s\n", strings.Join(removed, "\n  "))
+	fmt.Println()
+	fmt.Println("Claude Code will use its official setup on next start.")
+
+	return nil
+}
@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/nanmi/cc-portkey/internal/claude"
+	"github.com/spf13/cobra"
+)
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore [backup-id]",
+	Short: "Restore Claude settings from a backup",
+	Long: `Restore a Claude settings file from one of the automatic backups.
+
+cc-portkey snapshots a settings file into ~/.cc-portkey/backups/ before
+every write and keeps the most recent 20 snapshots of each file.
+
+Without an ID the newest backup is used. A diff against the current file
+is shown before anything is written.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runRestore,
+}
+
+var (
+	restoreList bool
+	restoreYes  bool
+)
+
+func init() {
+	restoreCmd.Flags().BoolVar(&restoreList, "list", false, "list available backups")
+	restoreCmd.Flags().BoolVarP(&restoreYes, "yes", "y", false, "restore without asking for confirmation")
+	rootCmd.AddCommand(restoreCmd)
+}
+
+func runRestore(cmd *cobra.Command, args []string) error {
+	if restoreList {
+		return listBackups()
+	}
+
+	id := ""
+	if len(args) > 0 {
+		id = args[0]
+	}
+
+	backup, err := claude.FindBackup(id)
+	if err != nil {
+		return err
+	}
+
+	current, snapshot, err := claude.RestoreContents(backup)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Backup %s of %s\n\n", cyan(backup.ID), backup.Source)
+	if !printDiff(backup.Source+" (current)", backup.ID+" (backup)", string(current), string(snapshot)) {
+		fmt.Printf("%s Settings already match this backup.\n", green("OK"))
+		return nil
+	}
+	fmt.Println()
+
+	if !restoreYes {
+		fmt.Print("Restore this backup? [y/N]: ")
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		answer = strings.ToLower(strings.TrimSpace(answer))
+		if answer != "y" && answer != "yes" {
+			fmt.Println("Aborted.")
+			return nil
+		}
+	}
+
+	if err := claude.RestoreBackup(backup, current); err != nil {
+		return fmt.Errorf("failed to restore backup: %w", err)
+	}
+
+	fmt.Printf("%s Restored %s from backup %s\n", green("OK"), backup.Source, backup.ID)
+	fmt.Println("The replaced file was backed up too, so this can be undone with 'cc-portkey restore'.")
+
+	return nil
+}
+
+// listBackups prints all available backups, newest first
+func listBackups() error {
+	backups, err := claude.ListBackups()
+	if err != nil {
+		return err
+	}
+
+	if len(backups) == 0 {
+		fmt.Println("No backups yet. One is taken automatically before every settings change.")
+		return nil
+	}
+
+	fmt.Println(bold("Backups:"))
+	fmt.Println()
+	for _, b := range backups {
+		fmt.Printf("  %-24s  %s  %s\n", cyan(b.ID), b.Created.Format("2006-01-02 15:04:05"), b.Source)
+	}
+	fmt.Println()
+	fmt.Printf("Use %s to restore one.\n", cyan("cc-portkey restore <id>"))
+
+	return nil
+}
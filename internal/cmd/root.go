@@ -3,7 +3,9 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"strings"
 
 	"github.com/fatih/color"
 	"github.com/nanmi/cc-portkey/internal/config"
@@ -11,13 +13,13 @@ import (
 )
 
 var (
-	Version   = "0.1.0"
-	cfgFile   string
-	green     = color.New(color.FgGreen).SprintFunc()
-	yellow    = color.New(color.FgYellow).SprintFunc()
-	red       = color.New(color.FgRed).SprintFunc()
-	cyan      = color.New(color.FgCyan).SprintFunc()
-	bold      = color.New(color.Bold).SprintFunc()
+	Version = "0.1.0"
+	cfgFile string
+	green   = color.New(color.FgGreen).SprintFunc()
+	yellow  = color.New(color.FgYellow).SprintFunc()
+	red     = color.New(color.FgRed).SprintFunc()
+	cyan    = color.New(color.FgCyan).SprintFunc()
+	bold    = color.New(color.Bold).SprintFunc()
 )
 
 var rootCmd = &cobra.Command{
@@ -30,6 +32,9 @@ Quick Start:
   cc-portkey edit     # Add your API keys
   ds / glm / mm / ccc # Launch Claude Code with different providers`,
 	Version: Version,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		config.SetConfigPath(cfgFile)
+	},
 }
 
 // Execute runs the root command
@@ -45,30 +50,103 @@ func Execute() {
 	}
 }
 
-// handleAlias checks if the program was invoked via a shortcut alias
-// and executes the corresponding 'use' command, then launches Claude Code
+// handleAlias checks if the program was invoked via a shortcut alias from
+// the config and executes the corresponding 'use' command, then launches
+// Claude Code
 func handleAlias() bool {
 	if len(os.Args) == 0 {
 		return false
 	}
 
-	basename := filepath.Base(os.Args[0])
+	basename := strings.TrimSuffix(filepath.Base(os.Args[0]), ".exe")
+	if basename == rootCmd.Name() || !invokedViaLink() {
+		return false
+	}
 
 	// Check if basename matches any alias
-	if profileName, ok := config.AliasMapping[basename]; ok {
-		// Switch profile and launch Claude Code CLI with remaining arguments
-		claudeArgs := os.Args[1:]
-		if err := switchToProfile(profileName, true, claudeArgs); err != nil {
-			fmt.Println(red("Error:"), err)
-			os.Exit(1)
+	claudeArgs := stripConfigFlag(os.Args[1:])
+	config.SetConfigPath(cfgFile)
+	cfg, err := config.Load()
+	if err != nil {
+		// Without a config there are no aliases; let the command run as usual
+		if !config.Exists() {
+			return false
+		}
+		fmt.Println(red("Error:"), err)
+		os.Exit(1)
+	}
+	alias, ok := cfg.Aliases[basename]
+	if !ok {
+		return false
+	}
+
+	// Switch profile and launch Claude Code CLI with the alias' arguments
+	// followed by the remaining ones.
+	// Aliases run in env-only mode so concurrent sessions don't fight over settings.json
+	opts := switchOptions{
+		Launch:     true,
+		EnvOnly:    true,
+		ClaudeArgs: append(append([]string{}, alias.Args...), claudeArgs...),
+		Env:        alias.Env,
+	}
+	if err := switchToProfile(alias.Profile, opts); err != nil {
+		fmt.Println(red("Error:"), err)
+		os.Exit(1)
+	}
+	return true
+}
+
+// invokedViaLink reports whether the program was started through a symlink
+// to its own executable, as 'cc-portkey link' creates for aliases. A copy or
+// renamed binary such as cc-portkey-dev is not an alias and runs as usual,
+// without touching the config before the command does.
+func invokedViaLink() bool {
+	invoked := os.Args[0]
+	if !strings.ContainsAny(invoked, `/\`) {
+		found, err := exec.LookPath(invoked)
+		if err != nil {
+			return false
 		}
-		return true
+		invoked = found
+	}
+	info, err := os.Lstat(invoked)
+	if err != nil || info.Mode()&os.ModeSymlink == 0 {
+		return false
 	}
 
-	return false
+	target, err := filepath.EvalSymlinks(invoked)
+	if err != nil {
+		return false
+	}
+	execPath, err := os.Executable()
+	if err != nil {
+		return false
+	}
+	if execPath, err = filepath.EvalSymlinks(execPath); err != nil {
+		return false
+	}
+	return target == execPath
+}
+
+// stripConfigFlag consumes a leading --config flag from alias arguments
+// so that e.g. "ds --config work.json" works like the main command.
+// Everything else is passed through to Claude Code untouched.
+func stripConfigFlag(args []string) []string {
+	if len(args) == 0 {
+		return args
+	}
+	if strings.HasPrefix(args[0], "--config=") {
+		cfgFile = strings.TrimPrefix(args[0], "--config=")
+		return args[1:]
+	}
+	if args[0] == "--config" && len(args) > 1 {
+		cfgFile = args[1]
+		return args[2:]
+	}
+	return args
 }
 
 func init() {
-	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file path (default ~/.cc-portkey/config.json)")
+	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file path; its vault, key rotation state and sessions are kept in its directory (default $"+config.ConfigEnvVar+" or ~/.cc-portkey/config.json)")
 	rootCmd.CompletionOptions.HiddenDefaultCmd = true
 }
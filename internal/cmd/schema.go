@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/nanmi/cc-portkey/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var schemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Print the JSON Schema for the config file",
+	Long: `Print a JSON Schema (draft-07) describing config.json.
+
+Editors that understand JSON Schema use it for completion and validation.
+With --write the schema is saved as config.schema.json next to the config
+file, which is what the "$schema" reference written by init points to.`,
+	Args: cobra.NoArgs,
+	RunE: runSchema,
+}
+
+var schemaWrite bool
+
+func init() {
+	schemaCmd.Flags().BoolVarP(&schemaWrite, "write", "w", false, "write config.schema.json next to the config file")
+	rootCmd.AddCommand(schemaCmd)
+}
+
+func runSchema(cmd *cobra.Command, args []string) error {
+	if !schemaWrite {
+		data, err := config.SchemaJSON()
+		if err != nil {
+			return err
+		}
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+
+	path, err := config.WriteSchema()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s Schema written to %s\n", green("Success!"), path)
+	return nil
+}
@@ -0,0 +1,227 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/nanmi/cc-portkey/internal/secret"
+	"github.com/spf13/cobra"
+)
+
+var secretCmd = &cobra.Command{
+	Use:   "secret",
+	Short: "Manage API keys in the encrypted secret vault",
+	Long: `Store API keys in a passphrase-encrypted vault (~/.cc-portkey/secrets.json)
+instead of in config.json or shell variables.
+
+Reference a stored secret from a profile as ${secret:NAME}, e.g.
+  "api_key": "${secret:deepseek}"
+
+After the passphrase is entered, an agent caches it for 15 minutes so
+shortcut launches don't ask again. Set $` + secret.AgentTTLEnvVar + ` to change
+the time (0 disables the agent), or $` + secret.PassphraseEnvVar + ` to supply
+the passphrase non-interactively.`,
+}
+
+var secretSetCmd = &cobra.Command{
+	Use:   "set <name>",
+	Short: "Store a secret, reading its value from the terminal or stdin",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runSecretSet,
+}
+
+var secretGetCmd = &cobra.Command{
+	Use:   "get <name>",
+	Short: "Print a secret",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runSecretGet,
+}
+
+var secretListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List stored secret names",
+	Args:    cobra.NoArgs,
+	RunE:    runSecretList,
+}
+
+var secretRemoveCmd = &cobra.Command{
+	Use:     "rm <name>",
+	Aliases: []string{"remove"},
+	Short:   "Remove a secret",
+	Args:    cobra.ExactArgs(1),
+	RunE:    runSecretRemove,
+}
+
+var secretUnlockCmd = &cobra.Command{
+	Use:   "unlock",
+	Short: "Cache the vault passphrase in the background agent",
+	Args:  cobra.NoArgs,
+	RunE:  runSecretUnlock,
+}
+
+var secretLockCmd = &cobra.Command{
+	Use:   "lock",
+	Short: "Stop the agent and forget the cached passphrase",
+	Args:  cobra.NoArgs,
+	RunE:  runSecretLock,
+}
+
+// secretAgentCmd is started by the other commands; it reads the
+// passphrase from stdin and serves it until the TTL expires
+var secretAgentCmd = &cobra.Command{
+	Use:    "agent",
+	Short:  "Run the passphrase agent",
+	Hidden: true,
+	Args:   cobra.NoArgs,
+	RunE:   runSecretAgent,
+}
+
+var secretTTL time.Duration
+
+func init() {
+	secretUnlockCmd.Flags().DurationVar(&secretTTL, "ttl", 0, "how long to cache the passphrase (default $"+secret.AgentTTLEnvVar+" or 15m)")
+	secretAgentCmd.Flags().DurationVar(&secretTTL, "ttl", secret.DefaultAgentTTL, "how long to serve the passphrase")
+	secretCmd.AddCommand(secretSetCmd, secretGetCmd, secretListCmd, secretRemoveCmd,
+		secretUnlockCmd, secretLockCmd, secretAgentCmd)
+	rootCmd.AddCommand(secretCmd)
+}
+
+func runSecretSet(cmd *cobra.Command, args []string) error {
+	name := args[0]
+	if err := secret.ValidateName(name); err != nil {
+		return err
+	}
+
+	passphrase, err := secret.Passphrase()
+	if err != nil {
+		return err
+	}
+	value, err := secret.ReadValue(fmt.Sprintf("Value for %s: ", name))
+	if err != nil {
+		return err
+	}
+	if value == "" {
+		return fmt.Errorf("secret value must not be empty")
+	}
+
+	err = secret.Update(passphrase, func(v *secret.Vault) error {
+		v.Set(name, value)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s Secret '%s' saved. Reference it as %s\n", green("OK"), name, cyan("${secret:"+name+"}"))
+	return nil
+}
+
+func runSecretGet(cmd *cobra.Command, args []string) error {
+	v, err := secret.Unlock()
+	if err != nil {
+		return err
+	}
+	value, ok := v.Get(args[0])
+	if !ok {
+		return fmt.Errorf("secret '%s' not found", args[0])
+	}
+	fmt.Println(value)
+	return nil
+}
+
+func runSecretList(cmd *cobra.Command, args []string) error {
+	if !secret.VaultExists() {
+		fmt.Println("No secrets stored. Add one with: cc-portkey secret set <name>")
+		return nil
+	}
+
+	v, err := secret.Unlock()
+	if err != nil {
+		return err
+	}
+	names := v.Names()
+	if len(names) == 0 {
+		fmt.Println("No secrets stored. Add one with: cc-portkey secret set <name>")
+		return nil
+	}
+	for _, name := range names {
+		fmt.Println(name)
+	}
+	return nil
+}
+
+func runSecretRemove(cmd *cobra.Command, args []string) error {
+	name := args[0]
+	if !secret.VaultExists() {
+		return fmt.Errorf("secret '%s' not found", name)
+	}
+
+	passphrase, err := secret.Passphrase()
+	if err != nil {
+		return err
+	}
+	err = secret.Update(passphrase, func(v *secret.Vault) error {
+		if !v.Delete(name) {
+			return fmt.Errorf("secret '%s' not found", name)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s Secret '%s' removed.\n", green("OK"), name)
+	return nil
+}
+
+func runSecretUnlock(cmd *cobra.Command, args []string) error {
+	if !secret.VaultExists() {
+		return fmt.Errorf("no secret vault found. Add a secret with 'cc-portkey secret set <name>'")
+	}
+
+	ttl := secretTTL
+	if ttl == 0 {
+		var err error
+		if ttl, err = secret.AgentTTL(); err != nil {
+			return err
+		}
+		if ttl == 0 {
+			return fmt.Errorf("the agent is disabled by %s=0; pass --ttl to override", secret.AgentTTLEnvVar)
+		}
+	}
+
+	passphrase, err := secret.PromptPassphrase()
+	if err != nil {
+		return err
+	}
+
+	// Restart so the new TTL applies
+	secret.StopAgent()
+	if err := secret.StartAgent(passphrase, ttl); err != nil {
+		return err
+	}
+
+	fmt.Printf("%s Vault unlocked for %s.\n", green("OK"), ttl)
+	return nil
+}
+
+func runSecretLock(cmd *cobra.Command, args []string) error {
+	if secret.StopAgent() {
+		fmt.Printf("%s Vault locked.\n", green("OK"))
+	} else {
+		fmt.Println("Vault is not unlocked.")
+	}
+	return nil
+}
+
+func runSecretAgent(cmd *cobra.Command, args []string) error {
+	passphrase, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("failed to read passphrase: %w", err)
+	}
+	return secret.RunAgent(strings.TrimRight(passphrase, "\r\n"), secretTTL)
+}
@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nanmi/cc-portkey/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var setCmd = &cobra.Command{
+	Use:   "set <profile> <path>=<value>...",
+	Short: "Set profile fields without opening an editor",
+	Long: `Set one or more fields of a profile, addressed by dotted paths.
+
+String fields take the value as is. Other fields parse it as JSON, falling
+back to a string, so api_key accepts a single key or a JSON list.
+
+Examples:
+  cc-portkey set glm models.haiku=glm-4.5-air
+  cc-portkey set deepseek timeout_ms=600000 'api_key=${DEEPSEEK_API_KEY}'
+  cc-portkey set kimi env.HTTPS_PROXY=http://127.0.0.1:7890
+  cc-portkey set minimax 'api_key=["${MINIMAX_KEY_1}","${MINIMAX_KEY_2}"]'`,
+	Args: cobra.MinimumNArgs(2),
+	RunE: runSet,
+}
+
+func init() {
+	rootCmd.AddCommand(setCmd)
+}
+
+func runSet(cmd *cobra.Command, args []string) error {
+	profileName := args[0]
+
+	type assignment struct{ path, value string }
+	var assignments []assignment
+	for _, arg := range args[1:] {
+		path, value, ok := strings.Cut(arg, "=")
+		if !ok {
+			return fmt.Errorf("invalid assignment %q, expected <path>=<value>", arg)
+		}
+		assignments = append(assignments, assignment{path, value})
+	}
+
+	isCurrent := false
+	err := config.Update(func(cfg *config.Config) error {
+		for _, a := range assignments {
+			if err := cfg.SetProfileValue(profileName, a.path, a.value); err != nil {
+				return err
+			}
+		}
+		isCurrent = cfg.Current == profileName
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, a := range assignments {
+		fmt.Printf("%s %s.%s updated.\n", green("OK"), profileName, a.path)
+	}
+	if isCurrent {
+		fmt.Printf("Run %s to apply the change.\n", cyan(fmt.Sprintf("cc-portkey use %s", profileName)))
+	}
+
+	return nil
+}
@@ -2,6 +2,8 @@ package cmd
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/nanmi/cc-portkey/internal/config"
 	"github.com/spf13/cobra"
@@ -38,32 +40,107 @@ func runShow(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("no profile specified and no current profile set")
 	}
 
-	profile, ok := cfg.Profiles[profileName]
-	if !ok {
+	if _, ok := cfg.Profiles[profileName]; !ok {
 		return fmt.Errorf("profile '%s' not found", profileName)
 	}
+	profile, sources, err := cfg.ResolveProfile(profileName)
+	if err != nil {
+		return err
+	}
+
+	// from marks values inherited through extends with their origin
+	from := func(field string) string {
+		if source, ok := sources[field]; ok && source != profileName {
+			return " " + yellow("(from "+source+")")
+		}
+		return ""
+	}
+	// entries lists map keys, each marked with its origin
+	entries := func(field string, names []string) string {
+		for i, name := range names {
+			names[i] = name + from(field+"."+name)
+		}
+		return strings.Join(names, ", ")
+	}
 
 	fmt.Printf("%s\n", bold(fmt.Sprintf("Profile: %s", profileName)))
 	fmt.Println()
 
-	fmt.Printf("  Display Name:  %s\n", profile.DisplayName)
+	fmt.Printf("  Display Name:  %s%s\n", profile.DisplayName, from("display_name"))
+	if profile.Extends != "" {
+		fmt.Printf("  Extends:       %s\n", profile.Extends)
+	}
 
-	if profile.BaseURL != "" {
-		fmt.Printf("  Base URL:      %s\n", profile.BaseURL)
+	if profile.IsSubscription() {
+		fmt.Printf("  Auth:          %s%s\n", cyan(config.SubscriptionLabel), from("kind"))
 	} else {
-		fmt.Printf("  Base URL:      %s\n", cyan("(official Claude API)"))
+		if profile.BaseURLCmd != "" {
+			fmt.Printf("  Base URL:      %s%s\n", cyan("(from command) "+profile.BaseURLCmd), from("base_url_cmd"))
+		} else if profile.BaseURL != "" {
+			fmt.Printf("  Base URL:      %s%s\n", profile.BaseURL, from("base_url"))
+		} else {
+			fmt.Printf("  Base URL:      %s\n", cyan("(official Claude API)"))
+		}
+
+		if profile.AuthType != "" && profile.AuthType != config.AuthBearer {
+			fmt.Printf("  Auth:          %s%s\n", profile.AuthType, from("auth_type"))
+		}
+
+		// Mask API key
+		switch {
+		case !profile.SendsKey():
+			// The auth type sends no key
+		case profile.APIKeyCmd != "":
+			fmt.Printf("  API Key:       %s%s\n", cyan("(from command) "+profile.APIKeyCmd), from("api_key_cmd"))
+		case profile.KeyCount() <= 1:
+			maskedKey := config.MaskAPIKey(strings.Join(profile.APIKey, ""))
+			fmt.Printf("  API Key:       %s%s\n", maskedKey, from("api_key"))
+		default:
+			strategy := profile.KeyStrategy
+			if strategy == "" {
+				strategy = config.KeyRoundRobin
+			}
+			fmt.Printf("  API Keys:      %d (%s)%s\n", profile.KeyCount(), strategy, from("api_key"))
+			printKeys(profileName, &profile)
+		}
+
+		// Header values often carry credentials, so only names are shown
+		if len(profile.Headers) > 0 {
+			fmt.Printf("  Headers:       %s\n", entries("headers", headerNames(&profile)))
+		}
+	}
+
+	if len(profile.Env) > 0 {
+		fmt.Printf("  Env:           %s\n", entries("env", envNames(&profile)))
 	}
 
-	// Mask API key
-	maskedKey := config.MaskAPIKey(profile.APIKey)
-	fmt.Printf("  API Key:       %s\n", maskedKey)
+	if overlay, err := profile.SettingsOverlay(); err == nil && len(overlay) > 0 {
+		paths := make([]string, 0, len(overlay))
+		for p := range overlay {
+			paths = append(paths, p)
+		}
+		sort.Strings(paths)
+		fmt.Printf("  Settings:      %s\n", entries("settings", paths))
+	}
 
-	fmt.Printf("  Timeout:       %dms\n", profile.TimeoutMS)
+	fmt.Printf("  Timeout:       %dms%s\n", profile.TimeoutMS, from("timeout_ms"))
 
 	if len(profile.Models) > 0 {
 		fmt.Printf("  Models:\n")
-		for key, value := range profile.Models {
-			fmt.Printf("    %-12s %s\n", key+":", value)
+		for _, slot := range config.ModelSlots {
+			if value, ok := profile.Models[slot.Key]; ok {
+				fmt.Printf("    %-12s %s%s\n", slot.Key+":", value, from("models."+slot.Key))
+			}
+		}
+		// Deprecated and unknown keys follow, flagged
+		for _, key := range sortedKeys(profile.Models) {
+			if slot, ok := config.ModelSlotFor(key); ok && slot.Key == key {
+				continue
+			}
+			fmt.Printf("    %-12s %s%s\n", key+":", profile.Models[key], from("models."+key))
+		}
+		for _, warning := range profile.ModelWarnings() {
+			fmt.Printf("    %s %s\n", yellow("Warning:"), warning)
 		}
 	}
 
@@ -74,3 +151,28 @@ func runShow(cmd *cobra.Command, args []string) error {
 
 	return nil
 }
+
+// headerNames returns the names of a profile's extra headers in sorted order
+func headerNames(profile *config.Profile) []string {
+	names := make([]string, 0, len(profile.Headers))
+	for name := range profile.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// envNames returns the names of a profile's extra env in sorted order
+func envNames(profile *config.Profile) []string {
+	return sortedKeys(profile.Env)
+}
+
+// sortedKeys returns the keys of m in sorted order
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -4,7 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"runtime"
+	"sort"
 
 	"github.com/nanmi/cc-portkey/internal/config"
 	"github.com/spf13/cobra"
@@ -16,8 +16,8 @@ var unlinkCmd = &cobra.Command{
 	Hidden: true,
 	Long: `Remove the symbolic links created by 'cc-portkey link'.
 
-Removes symlinks from the specified directory (default ~/.local/bin/):
-  cc, ds, glm, mm`,
+Removes the symlinks of the config's aliases, and any other symlink to
+cc-portkey, from the specified directory (default ~/.local/bin/).`,
 	Args: cobra.MaximumNArgs(1),
 	RunE: runUnlink,
 }
@@ -46,16 +46,27 @@ func runUnlink(cmd *cobra.Command, args []string) error {
 	}
 	execPath, _ = filepath.EvalSymlinks(execPath)
 
+	// Also take links of aliases since removed from the config
+	aliases := linkedAliases(targetDir, execPath)
+	if cfg, err := config.Load(); err == nil {
+		for _, alias := range aliasNames(cfg) {
+			if !containsString(aliases, alias) {
+				aliases = append(aliases, alias)
+			}
+		}
+	}
+	sort.Strings(aliases)
+
+	links, err := loadLinkRecord()
+	if err != nil {
+		return err
+	}
+
 	removed := 0
 	notFound := 0
 
-	for alias := range config.AliasMapping {
-		linkPath := filepath.Join(targetDir, alias)
-
-		// On Windows, add .exe extension
-		if runtime.GOOS == "windows" {
-			linkPath += ".exe"
-		}
+	for _, alias := range aliases {
+		linkPath := aliasPath(targetDir, alias)
 
 		// Check if link exists
 		fi, err := os.Lstat(linkPath)
@@ -83,15 +94,32 @@ func runUnlink(cmd *cobra.Command, args []string) error {
 		}
 
 		fmt.Printf("  %s -> removed\n", alias)
+		if abs, err := filepath.Abs(linkPath); err == nil {
+			delete(links, abs)
+		}
 		removed++
 	}
 
+	if err := saveLinkRecord(links); err != nil {
+		return err
+	}
+
 	fmt.Println()
 	if removed > 0 {
 		fmt.Printf("%s Removed %d symlink(s) from %s\n", green("OK"), removed, targetDir)
-	} else if notFound == len(config.AliasMapping) {
+	} else if notFound == len(aliases) {
 		fmt.Printf("No symlinks found in %s\n", targetDir)
 	}
 
 	return nil
 }
+
+// containsString reports whether list contains s
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/nanmi/cc-portkey/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var unsetCmd = &cobra.Command{
+	Use:   "unset <profile> <path>...",
+	Short: "Remove profile fields without opening an editor",
+	Long: `Remove one or more fields of a profile, addressed by dotted paths.
+
+Examples:
+  cc-portkey unset deepseek models.default
+  cc-portkey unset kimi env.HTTPS_PROXY timeout_ms`,
+	Args: cobra.MinimumNArgs(2),
+	RunE: runUnset,
+}
+
+func init() {
+	rootCmd.AddCommand(unsetCmd)
+}
+
+func runUnset(cmd *cobra.Command, args []string) error {
+	profileName := args[0]
+	paths := args[1:]
+
+	isCurrent := false
+	err := config.Update(func(cfg *config.Config) error {
+		for _, path := range paths {
+			if err := cfg.UnsetProfileValue(profileName, path); err != nil {
+				return err
+			}
+		}
+		isCurrent = cfg.Current == profileName
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+		fmt.Printf("%s %s.%s removed.\n", green("OK"), profileName, path)
+	}
+	if isCurrent {
+		fmt.Printf("Run %s to apply the change.\n", cyan(fmt.Sprintf("cc-portkey use %s", profileName)))
+	}
+
+	return nil
+}
@@ -4,54 +4,144 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"strings"
 	"syscall"
 
 	"github.com/nanmi/cc-portkey/internal/claude"
 	"github.com/nanmi/cc-portkey/internal/config"
+	"github.com/nanmi/cc-portkey/internal/keys"
 	"github.com/spf13/cobra"
 )
 
 var useCmd = &cobra.Command{
-	Use:   "use <profile>",
+	Use:   "use <profile> [-- claude args...]",
 	Short: "Switch to specified profile",
 	Long: `Switch Claude Code to use the specified profile's configuration.
 
 This updates ~/.claude/settings.json with the profile's base URL, API key,
-and model settings.`,
-	Args: cobra.ExactArgs(1),
+and model settings.
+
+Use --scope to limit the switch to the current project:
+  user     ~/.claude/settings.json (default, affects every project)
+  project  <repo>/.claude/settings.json (shared with the team)
+  local    <repo>/.claude/settings.local.json (personal, git-ignored)
+
+Use --launch to start Claude Code afterwards. Arguments after -- are passed
+to claude. With --launch --env-only the profile is injected only into the
+launched process and no settings file is touched, so concurrent sessions
+can use different providers (this is what the ds/glm/mm/ccc aliases do).`,
+	Args: cobra.MinimumNArgs(1),
 	RunE: runUse,
 }
 
+var (
+	useScope   string
+	useLaunch  bool
+	useEnvOnly bool
+)
+
+// switchOptions controls how switchToProfile applies a profile
+type switchOptions struct {
+	Scope      claude.Scope
+	Launch     bool              // start Claude Code after switching
+	EnvOnly    bool              // inject the profile into the launched process only
+	ClaudeArgs []string          // extra arguments for claude when launching
+	Env        map[string]string // env merged over the profile's own, from an alias
+}
+
 func init() {
+	useCmd.Flags().StringVarP(&useScope, "scope", "s", "user", "settings scope to write: user, project or local")
+	useCmd.Flags().BoolVarP(&useLaunch, "launch", "l", false, "start Claude Code after switching")
+	useCmd.Flags().BoolVar(&useEnvOnly, "env-only", false, "with --launch, set the profile only for the launched session without writing settings")
 	rootCmd.AddCommand(useCmd)
 }
 
 func runUse(cmd *cobra.Command, args []string) error {
-	return switchToProfile(args[0], false, nil)
+	scope, err := claude.ParseScope(useScope)
+	if err != nil {
+		return err
+	}
+	if useEnvOnly && !useLaunch {
+		return fmt.Errorf("--env-only requires --launch")
+	}
+	if len(args) > 1 && !useLaunch {
+		return fmt.Errorf("extra arguments are only allowed with --launch")
+	}
+
+	return switchToProfile(args[0], switchOptions{
+		Scope:      scope,
+		Launch:     useLaunch,
+		EnvOnly:    useEnvOnly,
+		ClaudeArgs: args[1:],
+	})
 }
 
-// switchToProfile switches to the specified profile
-// If launchClaude is true, starts Claude Code CLI after switching with given args
-func switchToProfile(profileName string, launchClaude bool, claudeArgs []string) error {
+// switchToProfile switches to the specified profile as described by opts
+func switchToProfile(profileName string, opts switchOptions) error {
 	cfg, err := config.Load()
 	if err != nil {
 		return err
 	}
 
-	profile, ok := cfg.Profiles[profileName]
-	if !ok {
+	if _, ok := cfg.Profiles[profileName]; !ok {
 		return fmt.Errorf("profile '%s' not found. Run 'cc-portkey list' to see available profiles", profileName)
 	}
+	profile, err := cfg.Profile(profileName)
+	if err != nil {
+		return err
+	}
 
-	// Apply profile to Claude settings
-	if err := claude.ApplyProfile(&profile); err != nil {
-		return fmt.Errorf("failed to apply profile: %w", err)
+	if len(opts.Env) > 0 {
+		env := make(map[string]string, len(profile.Env)+len(opts.Env))
+		for key, value := range profile.Env {
+			env[key] = value
+		}
+		for key, value := range opts.Env {
+			env[key] = value
+		}
+		profile.Env = env
 	}
 
-	// Update current profile in config
-	cfg.Current = profileName
-	if err := config.Save(cfg); err != nil {
-		return fmt.Errorf("failed to update config: %w", err)
+	// Pick from the profile's key pool
+	project, err := claude.ProjectRoot()
+	if err != nil {
+		return err
+	}
+	keyCount := profile.KeyCount()
+	keyIndex, err := keys.Select(profileName, &profile, project)
+	if err != nil {
+		return err
+	}
+	profile = profile.WithKey(keyIndex)
+
+	// Resolve references and run key commands up front so a failure aborts
+	// the switch before anything is written
+	profileEnv, err := claude.ProfileEnv(&profile)
+	if err != nil {
+		return fmt.Errorf("profile '%s': %w", profileName, err)
+	}
+	overlay, err := profile.SettingsOverlay()
+	if err != nil {
+		return fmt.Errorf("profile '%s': %w", profileName, err)
+	}
+
+	applied := settingsFor(cfg, profileName, profileEnv, overlay)
+	if !opts.EnvOnly {
+		// Apply profile to Claude settings
+		if err := claude.ApplyProfile(profileName, applied, opts.Scope); err != nil {
+			return fmt.Errorf("failed to apply profile: %w", err)
+		}
+
+		// The config's current profile tracks the user scope only
+		if opts.Scope == claude.ScopeUser {
+			err := config.Update(func(cfg *config.Config) error {
+				cfg.Current = profileName
+				return nil
+			})
+			if err != nil {
+				return fmt.Errorf("failed to update config: %w", err)
+			}
+		}
 	}
 
 	displayName := profile.DisplayName
@@ -60,41 +150,98 @@ func switchToProfile(profileName string, launchClaude bool, claudeArgs []string)
 	}
 
 	// Print switch confirmation with details
-	fmt.Printf("%s Switched to %s (%s)\n", green("OK"), cyan(profileName), displayName)
+	if opts.EnvOnly {
+		fmt.Printf("%s Using %s (%s) for this session\n", green("OK"), cyan(profileName), displayName)
+	} else {
+		fmt.Printf("%s Switched to %s (%s)\n", green("OK"), cyan(profileName), displayName)
+	}
 	fmt.Println()
 
-	// Expand env vars for display
-	expandedURL := config.ExpandEnv(profile.BaseURL)
-	expandedKey := config.ExpandEnv(profile.APIKey)
+	if !opts.EnvOnly && opts.Scope != claude.ScopeUser {
+		settingsPath, _ := claude.SettingsPathFor(opts.Scope)
+		fmt.Printf("  Scope:     %s (%s)\n", opts.Scope, settingsPath)
+	}
 
-	// Show Base URL
-	if expandedURL != "" {
-		fmt.Printf("  Base URL:  %s\n", expandedURL)
+	// Show the expanded values
+	if profile.IsSubscription() {
+		fmt.Printf("  Auth:      %s\n", cyan(config.SubscriptionLabel))
 	} else {
-		fmt.Printf("  Base URL:  %s\n", cyan("https://api.anthropic.com (Official)"))
+		printProfileEnv(&profile, profileEnv, keyIndex, keyCount)
 	}
 
-	// Show masked API Key
-	maskedKey := config.MaskAPIKey(expandedKey)
-	fmt.Printf("  API Key:   %s\n", maskedKey)
-
 	// Show model if configured
 	if model, ok := profile.Models["default"]; ok && model != "" {
 		fmt.Printf("  Model:     %s\n", model)
 	}
 
+	if withheldHeaders(cfg, profileEnv) {
+		fmt.Println()
+		fmt.Printf("%s key_helper is on, so the profile's headers were not written: apiKeyHelper can\n", yellow("Warning:"))
+		fmt.Printf("only supply the API key, and headers may hold credentials. Set %s to send them.\n", cyan("key_helper: false"))
+	}
+
+	if !opts.EnvOnly && opts.Scope == claude.ScopeProject && profile.SendsKey() {
+		fmt.Println()
+		fmt.Printf("%s .claude/settings.json is usually committed and now contains this profile's API key.\n", yellow("Note:"))
+		fmt.Printf("Use %s to keep it out of version control.\n", cyan("--scope local"))
+	}
+
 	// Launch Claude Code CLI if requested
-	if launchClaude {
+	if opts.Launch {
 		fmt.Println()
 		fmt.Printf("Starting Claude Code...\n\n")
-		return launchClaudeCLI(claudeArgs)
+		if opts.EnvOnly {
+			return launchClaudeSession(applied, opts.ClaudeArgs)
+		}
+		return launchClaudeCLI(opts.ClaudeArgs, os.Environ())
 	}
 
 	return nil
 }
 
+// printProfileEnv shows the base URL, masked key and headers an API
+// profile was applied with
+func printProfileEnv(profile *config.Profile, profileEnv map[string]string, keyIndex, keyCount int) {
+	if expandedURL := profileEnv["ANTHROPIC_BASE_URL"]; expandedURL != "" {
+		fmt.Printf("  Base URL:  %s\n", expandedURL)
+	} else {
+		fmt.Printf("  Base URL:  %s\n", cyan("https://api.anthropic.com (Official)"))
+	}
+
+	if profile.AuthType != "" && profile.AuthType != config.AuthBearer {
+		fmt.Printf("  Auth:      %s\n", profile.AuthType)
+	}
+
+	// Show masked API Key
+	if profile.SendsKey() {
+		maskedKey := config.MaskAPIKey(profileEnv["ANTHROPIC_AUTH_TOKEN"] + profileEnv["ANTHROPIC_API_KEY"])
+		if keyCount > 1 {
+			fmt.Printf("  API Key:   %s (key #%d of %d)\n", maskedKey, keyIndex+1, keyCount)
+		} else {
+			fmt.Printf("  API Key:   %s\n", maskedKey)
+		}
+	}
+
+	if len(profile.Headers) > 0 {
+		fmt.Printf("  Headers:   %s\n", strings.Join(headerNames(profile), ", "))
+	}
+}
+
+// launchClaudeSession starts Claude Code with the profile applied only to
+// the new process, leaving every settings file untouched. With key_helper
+// the key reaches neither the session file nor the process environment.
+func launchClaudeSession(applied *claude.Applied, claudeArgs []string) error {
+	settingsPath, err := claude.WriteSessionSettings(applied.Env, applied.Settings)
+	if err != nil {
+		return err
+	}
+
+	args := append([]string{"--settings", settingsPath}, claudeArgs...)
+	return launchClaudeCLI(args, claude.SessionEnv(applied.Env))
+}
+
 // launchClaudeCLI starts the Claude Code CLI, replacing the current process
-func launchClaudeCLI(claudeArgs []string) error {
+func launchClaudeCLI(claudeArgs []string, env []string) error {
 	claudePath, err := exec.LookPath("claude")
 	if err != nil {
 		return fmt.Errorf("claude command not found. Is Claude Code CLI installed?")
@@ -107,5 +254,5 @@ func launchClaudeCLI(claudeArgs []string) error {
 	}
 
 	// Replace current process with claude (exec)
-	return syscall.Exec(claudePath, args, os.Environ())
+	return syscall.Exec(claudePath, args, env)
 }
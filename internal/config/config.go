@@ -5,17 +5,38 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"regexp"
 	"strings"
+
+	"github.com/nanmi/cc-portkey/internal/fileutil"
 )
 
 const (
 	configDirName  = ".cc-portkey"
 	configFileName = "config.json"
+
+	// ConfigEnvVar names the environment variable that overrides the config file path
+	ConfigEnvVar = "CC_PORTKEY_CONFIG"
 )
 
+// pathOverride is set from the --config flag and takes precedence over ConfigEnvVar
+var pathOverride string
+
+// SetConfigPath overrides the configuration file path for this process.
+// An empty path restores the default lookup.
+func SetConfigPath(path string) {
+	pathOverride = path
+}
+
 // ConfigPath returns the path to the configuration file
+// Resolution order: --config flag, $CC_PORTKEY_CONFIG, ~/.cc-portkey/config.json
 func ConfigPath() (string, error) {
+	if pathOverride != "" {
+		return expandHome(pathOverride)
+	}
+	if path := os.Getenv(ConfigEnvVar); path != "" {
+		return expandHome(path)
+	}
+
 	home, err := os.UserHomeDir()
 	if err != nil {
 		return "", fmt.Errorf("failed to get home directory: %w", err)
@@ -23,8 +44,39 @@ func ConfigPath() (string, error) {
 	return filepath.Join(home, configDirName, configFileName), nil
 }
 
-// ConfigDir returns the path to the configuration directory
+// expandHome expands a leading ~ and returns an absolute path
+func expandHome(path string) (string, error) {
+	if path == "~" || strings.HasPrefix(path, "~/") || strings.HasPrefix(path, `~\`) {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to get home directory: %w", err)
+		}
+		path = filepath.Join(home, path[1:])
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve config path %s: %w", path, err)
+	}
+	return abs, nil
+}
+
+// ConfigDir returns the directory holding the configuration file, where the
+// state that belongs to that config lives: the vault, key rotation state and
+// session files. A config chosen with --config or $CC_PORTKEY_CONFIG keeps
+// this state apart from ~/.cc-portkey.
 func ConfigDir() (string, error) {
+	path, err := ConfigPath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Dir(path), nil
+}
+
+// StateDir returns ~/.cc-portkey whichever config is in use. It holds the
+// state tied to Claude settings files rather than to a config: the settings
+// lock, the manifest and the backups, so every config editing the same
+// settings file shares them.
+func StateDir() (string, error) {
 	home, err := os.UserHomeDir()
 	if err != nil {
 		return "", fmt.Errorf("failed to get home directory: %w", err)
@@ -32,13 +84,36 @@ func ConfigDir() (string, error) {
 	return filepath.Join(home, configDirName), nil
 }
 
-// Load reads and parses the configuration file
+// Load reads and parses the configuration file.
+// Files written by an older version are migrated and saved, keeping a backup.
 func Load() (*Config, error) {
 	path, err := ConfigPath()
 	if err != nil {
 		return nil, err
 	}
 
+	data, err := readConfig(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, plan, err := decode(data)
+	if err != nil {
+		return nil, err
+	}
+	if !plan.Pending() {
+		return cfg, nil
+	}
+
+	err = fileutil.WithLock(lockPath(path), func() error {
+		cfg, _, err = loadLocked(path)
+		return err
+	})
+	return cfg, err
+}
+
+// readConfig reads the raw configuration file at path
+func readConfig(path string) ([]byte, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -46,46 +121,81 @@ func Load() (*Config, error) {
 		}
 		return nil, fmt.Errorf("failed to read config file: %w", err)
 	}
+	return data, nil
+}
 
-	var cfg Config
-	if err := json.Unmarshal(data, &cfg); err != nil {
-		return nil, fmt.Errorf("failed to parse config file: %w", err)
+// Save writes the configuration to file
+func Save(cfg *Config) error {
+	path, err := ConfigPath()
+	if err != nil {
+		return err
 	}
 
-	return &cfg, nil
+	return fileutil.WithLock(lockPath(path), func() error {
+		return save(path, cfg)
+	})
 }
 
-// Save writes the configuration to file
-func Save(cfg *Config) error {
+// Update runs a locked read-modify-write cycle on the configuration file,
+// so concurrent invocations can't lose each other's changes
+func Update(fn func(cfg *Config) error) error {
 	path, err := ConfigPath()
 	if err != nil {
 		return err
 	}
 
+	return fileutil.WithLock(lockPath(path), func() error {
+		cfg, _, err := loadLocked(path)
+		if err != nil {
+			return err
+		}
+		if err := fn(cfg); err != nil {
+			return err
+		}
+		return save(path, cfg)
+	})
+}
+
+// save writes the configuration to path; the caller must hold the lock
+func save(path string, cfg *Config) error {
+	if err := cfg.checkExtends(); err != nil {
+		return err
+	}
+
 	dir := filepath.Dir(path)
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return fmt.Errorf("failed to create config directory: %w", err)
 	}
 
-	data, err := json.MarshalIndent(cfg, "", "  ")
+	data, err := marshal(cfg)
 	if err != nil {
-		return fmt.Errorf("failed to marshal config: %w", err)
-	}
-
-	// Write to temp file first for atomic operation
-	tmpPath := path + ".tmp"
-	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
-		return fmt.Errorf("failed to write config file: %w", err)
+		return err
 	}
 
-	if err := os.Rename(tmpPath, path); err != nil {
-		os.Remove(tmpPath)
+	fileutil.CleanTemps(path)
+	if err := fileutil.WriteFileAtomic(path, data, 0600); err != nil {
 		return fmt.Errorf("failed to save config file: %w", err)
 	}
 
 	return nil
 }
 
+// marshal encodes the configuration the way it is written to disk,
+// stamped with the current schema version
+func marshal(cfg *Config) ([]byte, error) {
+	cfg.Version = CurrentVersion
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal config: %w", err)
+	}
+	return data, nil
+}
+
+// lockPath returns the lock file guarding the configuration file
+func lockPath(path string) string {
+	return path + ".lock"
+}
+
 // Exists checks if the configuration file exists
 func Exists() bool {
 	path, err := ConfigPath()
@@ -96,19 +206,6 @@ func Exists() bool {
 	return err == nil
 }
 
-// ExpandEnv expands environment variable references in a string
-// Supports ${VAR_NAME} syntax
-func ExpandEnv(s string) string {
-	re := regexp.MustCompile(`\$\{([^}]+)\}`)
-	return re.ReplaceAllStringFunc(s, func(match string) string {
-		varName := strings.TrimPrefix(strings.TrimSuffix(match, "}"), "${")
-		if val := os.Getenv(varName); val != "" {
-			return val
-		}
-		return match // Keep original if env var not found
-	})
-}
-
 // MaskAPIKey masks an API key for display, showing only first and last 4 characters
 func MaskAPIKey(key string) string {
 	if len(key) <= 12 {
@@ -0,0 +1,204 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// StringList is a list of strings that may be written in JSON as a
+// single string or as an array
+type StringList []string
+
+// UnmarshalJSON accepts "a" as well as ["a", "b"]
+func (l *StringList) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*l = StringList{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("expected a string or an array of strings")
+	}
+	*l = list
+	return nil
+}
+
+// MarshalJSON writes a single entry as a plain string and no entries as ""
+func (l StringList) MarshalJSON() ([]byte, error) {
+	switch len(l) {
+	case 0:
+		return []byte(`""`), nil
+	case 1:
+		return json.Marshal(l[0])
+	}
+	return json.Marshal([]string(l))
+}
+
+// jsonSchema describes both accepted forms
+func (StringList) jsonSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"oneOf": []interface{}{
+			map[string]interface{}{"type": "string"},
+			map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+		},
+	}
+}
+
+// Lookup returns the value of a variable and whether it is set
+type Lookup func(name string) (string, bool)
+
+// Lookup returns how the profile's references find variables: the process
+// environment first, then the profile's env files, later files overriding
+// earlier ones. Relative env file paths are taken from the current directory.
+func (p *Profile) Lookup() (Lookup, error) {
+	if len(p.EnvFile) == 0 {
+		return os.LookupEnv, nil
+	}
+
+	vars := make(map[string]string)
+	for _, file := range p.EnvFile {
+		path, err := expandHome(file)
+		if err != nil {
+			return nil, err
+		}
+		fileVars, err := LoadEnvFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("env_file: %w", err)
+		}
+		for name, value := range fileVars {
+			vars[name] = value
+		}
+	}
+
+	return func(name string) (string, bool) {
+		if value, ok := os.LookupEnv(name); ok {
+			return value, true
+		}
+		value, ok := vars[name]
+		return value, ok
+	}, nil
+}
+
+// LoadEnvFile parses a dotenv file. Each line is NAME=value, optionally
+// prefixed with "export". Values may be single-quoted (taken literally) or
+// double-quoted (with \n, \t, \" and \\ escapes); unquoted values end at
+// " #". Blank lines and lines starting with # are ignored.
+func LoadEnvFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	vars := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		name, value, err := parseEnvLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, lineNo, err)
+		}
+		vars[name] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return vars, nil
+}
+
+// parseEnvLine parses a single non-blank, non-comment dotenv line
+func parseEnvLine(line string) (string, string, error) {
+	if rest, ok := strings.CutPrefix(line, "export "); ok {
+		line = strings.TrimSpace(rest)
+	}
+
+	name, value, ok := strings.Cut(line, "=")
+	if !ok {
+		return "", "", fmt.Errorf("expected NAME=value")
+	}
+	name = strings.TrimSpace(name)
+	if name == "" || !isValidName(name) {
+		return "", "", fmt.Errorf("invalid variable name %q", name)
+	}
+	value = strings.TrimSpace(value)
+
+	switch {
+	case strings.HasPrefix(value, "'"):
+		end := strings.IndexByte(value[1:], '\'')
+		if end < 0 {
+			return "", "", fmt.Errorf("unterminated single-quoted value")
+		}
+		if err := checkTrailing(value[end+2:]); err != nil {
+			return "", "", err
+		}
+		return name, value[1 : end+1], nil
+
+	case strings.HasPrefix(value, `"`):
+		var b strings.Builder
+		for i := 1; i < len(value); i++ {
+			c := value[i]
+			if c == '"' {
+				if err := checkTrailing(value[i+1:]); err != nil {
+					return "", "", err
+				}
+				return name, b.String(), nil
+			}
+			if c == '\\' && i+1 < len(value) {
+				i++
+				switch value[i] {
+				case 'n':
+					b.WriteByte('\n')
+				case 't':
+					b.WriteByte('\t')
+				case 'r':
+					b.WriteByte('\r')
+				default:
+					b.WriteByte(value[i])
+				}
+				continue
+			}
+			b.WriteByte(c)
+		}
+		return "", "", fmt.Errorf("unterminated double-quoted value")
+	}
+
+	if i := strings.Index(value, " #"); i >= 0 {
+		value = strings.TrimSpace(value[:i])
+	}
+	return name, value, nil
+}
+
+// checkTrailing allows only whitespace and a comment after a quoted value
+func checkTrailing(rest string) error {
+	rest = strings.TrimSpace(rest)
+	if rest != "" && !strings.HasPrefix(rest, "#") {
+		return fmt.Errorf("unexpected %q after quoted value", rest)
+	}
+	return nil
+}
+
+// ValidateEnvName checks that name can be used as an environment variable
+func ValidateEnvName(name string) error {
+	if name == "" || !isValidName(name) {
+		return fmt.Errorf("invalid variable name %q", name)
+	}
+	return nil
+}
+
+// isValidName reports whether name is a valid variable name
+func isValidName(name string) bool {
+	for i := 0; i < len(name); i++ {
+		if !isNameChar(name[i]) || i == 0 && name[i] >= '0' && name[i] <= '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,114 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseEnvLine(t *testing.T) {
+	tests := []struct {
+		name      string
+		line      string
+		wantName  string
+		wantValue string
+		wantErr   string
+	}{
+		{name: "plain", line: "KEY=sk-1", wantName: "KEY", wantValue: "sk-1"},
+		{name: "spaces around", line: "KEY = sk-1 ", wantName: "KEY", wantValue: "sk-1"},
+		{name: "empty value", line: "KEY=", wantName: "KEY", wantValue: ""},
+		{name: "value with =", line: "URL=https://x?a=b", wantName: "URL", wantValue: "https://x?a=b"},
+		{name: "export", line: "export KEY=sk-1", wantName: "KEY", wantValue: "sk-1"},
+		{name: "export with extra spaces", line: "export   KEY=sk-1", wantName: "KEY", wantValue: "sk-1"},
+		{name: "name starting with export", line: "exported=1", wantName: "exported", wantValue: "1"},
+
+		{name: "trailing comment", line: "KEY=sk-1 # work key", wantName: "KEY", wantValue: "sk-1"},
+		{name: "hash without space", line: "KEY=sk#1", wantName: "KEY", wantValue: "sk#1"},
+
+		{name: "single quotes", line: `KEY='a b'`, wantName: "KEY", wantValue: "a b"},
+		{name: "single quotes are literal", line: `KEY='a\n$HOME # x'`, wantName: "KEY", wantValue: `a\n$HOME # x`},
+		{name: "single quotes with comment", line: `KEY='a' # note`, wantName: "KEY", wantValue: "a"},
+		{name: "empty single quotes", line: `KEY=''`, wantName: "KEY", wantValue: ""},
+
+		{name: "double quotes", line: `KEY="a b"`, wantName: "KEY", wantValue: "a b"},
+		{name: "double quote escapes", line: `KEY="a\nb\tc\r\"d\"\\e"`, wantName: "KEY", wantValue: "a\nb\tc\r\"d\"\\e"},
+		{name: "unknown escape keeps the character", line: `KEY="\$x"`, wantName: "KEY", wantValue: "$x"},
+		{name: "double quotes keep #", line: `KEY="a # b"`, wantName: "KEY", wantValue: "a # b"},
+		{name: "double quotes with comment", line: `KEY="a" # note`, wantName: "KEY", wantValue: "a"},
+
+		{name: "no equals", line: "KEY", wantErr: "expected NAME=value"},
+		{name: "empty name", line: "=x", wantErr: `invalid variable name ""`},
+		{name: "name with dash", line: "MY-KEY=x", wantErr: `invalid variable name "MY-KEY"`},
+		{name: "name starting with digit", line: "1KEY=x", wantErr: `invalid variable name "1KEY"`},
+		{name: "unterminated single quote", line: "KEY='a", wantErr: "unterminated single-quoted value"},
+		{name: "unterminated double quote", line: `KEY="a`, wantErr: "unterminated double-quoted value"},
+		{name: "escaped closing quote", line: `KEY="a\"`, wantErr: "unterminated double-quoted value"},
+		{name: "text after single quotes", line: `KEY='a'b`, wantErr: `unexpected "b" after quoted value`},
+		{name: "text after double quotes", line: `KEY="a" b`, wantErr: `unexpected "b" after quoted value`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name, value, err := parseEnvLine(tt.line)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got %q=%q, %v; want error containing %q", name, value, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if name != tt.wantName || value != tt.wantValue {
+				t.Errorf("got %q=%q, want %q=%q", name, value, tt.wantName, tt.wantValue)
+			}
+		})
+	}
+}
+
+func TestLoadEnvFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, ".env")
+	content := `# provider keys
+
+export WORK_KEY="sk-work" # from the dashboard
+  # indented comment
+HOME_KEY='sk-home'
+PROXY=http://127.0.0.1:7890
+WORK_KEY=sk-work-2
+`
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := LoadEnvFile(path)
+	if err != nil {
+		t.Fatalf("LoadEnvFile: %v", err)
+	}
+	want := map[string]string{
+		"WORK_KEY": "sk-work-2", // later lines win
+		"HOME_KEY": "sk-home",
+		"PROXY":    "http://127.0.0.1:7890",
+	}
+	if len(got) != len(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	for name, value := range want {
+		if got[name] != value {
+			t.Errorf("%s = %q, want %q", name, got[name], value)
+		}
+	}
+
+	bad := filepath.Join(dir, "bad.env")
+	if err := os.WriteFile(bad, []byte("A=1\n\nB\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadEnvFile(bad); err == nil || !strings.Contains(err.Error(), "bad.env:3: expected NAME=value") {
+		t.Errorf("got %v, want an error for line 3", err)
+	}
+
+	if _, err := LoadEnvFile(filepath.Join(dir, "missing.env")); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
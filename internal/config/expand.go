@@ -0,0 +1,257 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Values in a profile may reference the environment with a subset of the
+// shell's parameter expansion:
+//
+//	${VAR}            value of VAR; an error if VAR is unset or empty
+//	${VAR:-default}   default if VAR is unset or empty (${VAR-default}: unset only)
+//	${VAR:+alt}       alt if VAR is set and non-empty (${VAR+alt}: set)
+//	${VAR:?message}   an error with message if VAR is unset or empty (${VAR?message}: unset)
+//	${scheme:name}    a value from a registered resolver, e.g. ${secret:NAME}
+//	$$                a literal $
+//
+// Defaults, alternatives and messages are expanded themselves, so references
+// nest: ${WORK_KEY:-${HOME_KEY}}. A $ not followed by { or $ is kept as is.
+
+// Resolver returns the value of a ${scheme:name} reference
+type Resolver func(name string) (string, error)
+
+// resolvers maps reference schemes such as "secret" to their resolvers
+var resolvers = make(map[string]Resolver)
+
+// RegisterResolver makes ${scheme:name} references resolve through r
+func RegisterResolver(scheme string, r Resolver) {
+	resolvers[scheme] = r
+}
+
+// UnsetVarError reports a required variable that is not set, or is empty
+type UnsetVarError struct {
+	Name    string
+	Message string // from ${VAR:?message}, if any
+	Empty   bool   // set, but to an empty value
+}
+
+func (e *UnsetVarError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("%s: %s", e.Name, e.Message)
+	}
+	if e.Empty {
+		return fmt.Sprintf("variable %s is empty", e.Name)
+	}
+	return fmt.Sprintf("variable %s is not set", e.Name)
+}
+
+// expander evaluates references in a string
+type expander struct {
+	lookup Lookup
+
+	// scan records problems instead of failing and leaves resolvers alone,
+	// so a value can be checked without prompting or running anything
+	scan    bool
+	missing []string
+	refs    map[string][]string // scheme -> names, filled while scanning
+}
+
+// Expand expands references in s against the process environment.
+// An unset required variable, a failing resolver or a malformed reference
+// is an error.
+func Expand(s string) (string, error) {
+	return ExpandWith(s, os.LookupEnv)
+}
+
+// ExpandWith is Expand with variables looked up through lookup
+func ExpandWith(s string, lookup Lookup) (string, error) {
+	e := &expander{lookup: lookup}
+	return e.expand(s)
+}
+
+// UnresolvedEnvVars returns the variables s requires that lookup doesn't
+// find. The error reports a malformed reference.
+func UnresolvedEnvVars(s string, lookup Lookup) ([]string, error) {
+	e := &expander{lookup: lookup, scan: true}
+	_, err := e.expand(s)
+	return e.missing, err
+}
+
+// References returns the names of the ${scheme:name} references in s
+func References(s, scheme string) []string {
+	e := &expander{lookup: os.LookupEnv, scan: true}
+	e.expand(s)
+	return e.refs[scheme]
+}
+
+// expand evaluates a whole value
+func (e *expander) expand(s string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		if s[i] != '$' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			i++
+			continue
+		}
+		switch s[i+1] {
+		case '$':
+			b.WriteByte('$')
+			i += 2
+		case '{':
+			value, next, err := e.reference(s, i)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(value)
+			i = next
+		default:
+			b.WriteByte('$')
+			i++
+		}
+	}
+	return b.String(), nil
+}
+
+// reference evaluates the ${...} starting at s[start] and returns its value
+// and the index just past the closing brace
+func (e *expander) reference(s string, start int) (string, int, error) {
+	i := start + 2
+	j := i
+	for j < len(s) && isNameChar(s[j]) {
+		j++
+	}
+	name := s[i:j]
+	if name == "" || j == len(s) {
+		return "", 0, syntaxError(s, start)
+	}
+
+	if s[j] == '}' {
+		value, err := e.variable(name, 0, false, "")
+		return value, j + 1, err
+	}
+
+	// ${scheme:name} for registered schemes
+	if _, ok := resolvers[name]; ok && s[j] == ':' && j+1 < len(s) && !strings.ContainsRune("-+?", rune(s[j+1])) {
+		end := strings.IndexByte(s[j+1:], '}')
+		if end < 0 {
+			return "", 0, syntaxError(s, start)
+		}
+		value, err := e.resolve(name, s[j+1:j+1+end])
+		return value, j + 1 + end + 1, err
+	}
+
+	colon := false
+	if s[j] == ':' {
+		colon = true
+		j++
+	}
+	if j == len(s) || !strings.ContainsRune("-+?", rune(s[j])) {
+		return "", 0, syntaxError(s, start)
+	}
+	op := s[j]
+	end, ok := wordEnd(s, j+1)
+	if !ok {
+		return "", 0, syntaxError(s, start)
+	}
+
+	value, err := e.variable(name, op, colon, s[j+1:end])
+	return value, end + 1, err
+}
+
+// variable applies operator op to the variable name. word is only expanded
+// when the operator needs it, as in the shell.
+func (e *expander) variable(name string, op byte, colon bool, word string) (string, error) {
+	value, exists := e.lookup(name)
+	set := exists && !(colon && value == "")
+
+	switch op {
+	case 0:
+		// A plain ${VAR} requires a value, so an empty key or URL can't
+		// slip through; ${VAR-} accepts an empty one
+		if value == "" {
+			return e.unset(name, "", exists)
+		}
+	case '-':
+		if !set {
+			return e.expand(word)
+		}
+	case '+':
+		if !set {
+			return "", nil
+		}
+		return e.expand(word)
+	case '?':
+		if !set {
+			message, err := e.expand(word)
+			if err != nil {
+				return "", err
+			}
+			return e.unset(name, message, exists && value == "")
+		}
+	}
+	return value, nil
+}
+
+// unset fails on a required variable, or records it while scanning
+func (e *expander) unset(name, message string, empty bool) (string, error) {
+	if e.scan {
+		e.missing = append(e.missing, name)
+		return "", nil
+	}
+	return "", &UnsetVarError{Name: name, Message: message, Empty: empty}
+}
+
+// resolve looks up a ${scheme:name} reference
+func (e *expander) resolve(scheme, name string) (string, error) {
+	if e.scan {
+		if e.refs == nil {
+			e.refs = make(map[string][]string)
+		}
+		e.refs[scheme] = append(e.refs[scheme], name)
+		return "", nil
+	}
+
+	value, err := resolvers[scheme](name)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve ${%s:%s}: %w", scheme, name, err)
+	}
+	return value, nil
+}
+
+// wordEnd returns the index of the brace closing a word that starts at
+// s[i], skipping nested references
+func wordEnd(s string, i int) (int, bool) {
+	depth := 0
+	for ; i < len(s); i++ {
+		switch {
+		case s[i] == '$' && i+1 < len(s) && s[i+1] == '$':
+			i++
+		case s[i] == '$' && i+1 < len(s) && s[i+1] == '{':
+			depth++
+			i++
+		case s[i] == '}':
+			if depth == 0 {
+				return i, true
+			}
+			depth--
+		}
+	}
+	return 0, false
+}
+
+// isNameChar reports whether c may appear in a variable or scheme name
+func isNameChar(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// syntaxError describes a malformed reference starting at s[start]
+func syntaxError(s string, start int) error {
+	ref := s[start:]
+	if end := strings.IndexByte(ref, '}'); end >= 0 {
+		ref = ref[:end+1]
+		return fmt.Errorf("bad substitution %q", ref)
+	}
+	return fmt.Errorf("unterminated reference %q", ref)
+}
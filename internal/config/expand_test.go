@@ -0,0 +1,193 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// env is a Lookup over a fixed set of variables
+func env(vars map[string]string) Lookup {
+	return func(name string) (string, bool) {
+		value, ok := vars[name]
+		return value, ok
+	}
+}
+
+func TestExpandWith(t *testing.T) {
+	vars := env(map[string]string{
+		"KEY":   "sk-1",
+		"EMPTY": "",
+		"HOST":  "api.example.com",
+	})
+
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr string // substring of the error, if one is expected
+	}{
+		{name: "plain text", input: "https://api.example.com", want: "https://api.example.com"},
+		{name: "variable", input: "${KEY}", want: "sk-1"},
+		{name: "variable in text", input: "https://${HOST}/v1", want: "https://api.example.com/v1"},
+		{name: "unset variable", input: "${MISSING}", wantErr: "variable MISSING is not set"},
+		{name: "empty variable", input: "${EMPTY}", wantErr: "variable EMPTY is empty"},
+
+		{name: "default when unset", input: "${MISSING:-x}", want: "x"},
+		{name: "default when empty", input: "${EMPTY:-x}", want: "x"},
+		{name: "no default when set", input: "${KEY:-x}", want: "sk-1"},
+		{name: "unset-only default skips empty", input: "${EMPTY-x}", want: ""},
+		{name: "unset-only default when unset", input: "${MISSING-x}", want: "x"},
+		{name: "empty default", input: "${MISSING:-}", want: ""},
+
+		{name: "alternative when set", input: "${KEY:+y}", want: "y"},
+		{name: "no alternative when empty", input: "${EMPTY:+y}", want: ""},
+		{name: "set-only alternative when empty", input: "${EMPTY+y}", want: "y"},
+		{name: "no alternative when unset", input: "${MISSING+y}", want: ""},
+
+		{name: "required when set", input: "${KEY:?need it}", want: "sk-1"},
+		{name: "required when unset", input: "${MISSING:?need it}", wantErr: "MISSING: need it"},
+		{name: "required when empty", input: "${EMPTY:?need it}", wantErr: "EMPTY: need it"},
+		{name: "unset-only required accepts empty", input: "${EMPTY?need it}", want: ""},
+
+		{name: "nested default", input: "${MISSING:-${KEY}}", want: "sk-1"},
+		{name: "doubly nested default", input: "${MISSING:-${ALSO_MISSING:-${HOST}}}", want: "api.example.com"},
+		{name: "nested default only expanded when used", input: "${KEY:-${MISSING}}", want: "sk-1"},
+		{name: "nested unset default", input: "${MISSING:-${ALSO_MISSING}}", wantErr: "ALSO_MISSING is not set"},
+		{name: "nested message", input: "${MISSING:?set ${HOST}}", wantErr: "MISSING: set api.example.com"},
+
+		{name: "escaped dollar", input: "$$", want: "$"},
+		{name: "escaped reference", input: "$${KEY}", want: "${KEY}"},
+		{name: "escaped dollar in default", input: "${MISSING:-a$$b}", want: "a$b"},
+		{name: "escaped brace in default", input: "${MISSING:-$${x}}", want: "${x}"},
+		{name: "lone dollar", input: "a$b $", want: "a$b $"},
+
+		{name: "empty name", input: "${}", wantErr: `bad substitution "${}"`},
+		{name: "unterminated", input: "${KEY", wantErr: `unterminated reference "${KEY"`},
+		{name: "unterminated default", input: "${MISSING:-x", wantErr: "unterminated reference"},
+		{name: "unknown operator", input: "${KEY#x}", wantErr: `bad substitution "${KEY#x}"`},
+		{name: "colon without operator", input: "${KEY:x}", wantErr: "bad substitution"},
+		{name: "bad character in name", input: "${KEY.x}", wantErr: "bad substitution"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ExpandWith(tt.input, vars)
+			if tt.wantErr != "" {
+				if err == nil {
+					t.Fatalf("got %q, want error containing %q", got, tt.wantErr)
+				}
+				if !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error %q, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandWithUnsetVarError(t *testing.T) {
+	vars := env(map[string]string{"EMPTY": ""})
+
+	tests := []struct {
+		input string
+		want  UnsetVarError
+	}{
+		{input: "${MISSING}", want: UnsetVarError{Name: "MISSING"}},
+		{input: "${EMPTY}", want: UnsetVarError{Name: "EMPTY", Empty: true}},
+		{input: "${MISSING:?set it}", want: UnsetVarError{Name: "MISSING", Message: "set it"}},
+		{input: "${EMPTY:?set it}", want: UnsetVarError{Name: "EMPTY", Message: "set it", Empty: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			_, err := ExpandWith(tt.input, vars)
+			var unset *UnsetVarError
+			if !errors.As(err, &unset) {
+				t.Fatalf("got %v, want an UnsetVarError", err)
+			}
+			if *unset != tt.want {
+				t.Errorf("got %+v, want %+v", *unset, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnresolvedEnvVars(t *testing.T) {
+	vars := env(map[string]string{"KEY": "sk-1", "EMPTY": ""})
+
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		{input: "${KEY}", want: nil},
+		{input: "${MISSING}", want: []string{"MISSING"}},
+		{input: "${EMPTY}", want: []string{"EMPTY"}},
+		{input: "${MISSING:-x}", want: nil},
+		{input: "${MISSING:-${OTHER}}", want: []string{"OTHER"}},
+		{input: "${A}/${B:?b}", want: []string{"A", "B"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := UnresolvedEnvVars(tt.input, vars)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandWithResolver(t *testing.T) {
+	RegisterResolver("test", func(name string) (string, error) {
+		if name == "bad" {
+			return "", errors.New("locked")
+		}
+		return "resolved-" + name, nil
+	})
+	defer delete(resolvers, "test")
+
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr string
+	}{
+		{name: "reference", input: "${test:KEY}", want: "resolved-KEY"},
+		{name: "reference in default", input: "${MISSING:-${test:KEY}}", want: "resolved-KEY"},
+		{name: "failing resolver", input: "${test:bad}", wantErr: "failed to resolve ${test:bad}: locked"},
+		{name: "unknown scheme", input: "${nope:KEY}", wantErr: "bad substitution"},
+		{name: "scheme as variable with default", input: "${test:-x}", want: "x"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ExpandWith(tt.input, env(nil))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got %q, %v; want error containing %q", got, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if got := References("${test:A} and ${MISSING:-${test:B}}", "test"); strings.Join(got, ",") != "A,B" {
+		t.Errorf("References = %v, want [A B]", got)
+	}
+}
@@ -0,0 +1,195 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Profile returns the named profile with its extends chain applied
+func (c *Config) Profile(name string) (Profile, error) {
+	profile, _, err := c.ResolveProfile(name)
+	return profile, err
+}
+
+// ResolveProfile applies the named profile's extends chain and reports,
+// for each field set, the profile it came from. Map fields are merged key
+// by key and reported as "models.opus", "env.HTTPS_PROXY" and so on; other
+// fields are taken from the nearest profile in the chain that sets them,
+// even to an empty value such as "base_url": "".
+func (c *Config) ResolveProfile(name string) (Profile, map[string]string, error) {
+	chain, err := c.extendsChain(name)
+	if err != nil {
+		return Profile{}, nil, err
+	}
+
+	var resolved Profile
+	sources := make(map[string]string)
+	for i := len(chain) - 1; i >= 0; i-- {
+		overlay(&resolved, c.Profiles[chain[i]], chain[i], sources)
+	}
+	resolved.Extends = c.Profiles[name].Extends
+	return resolved, sources, nil
+}
+
+// extendsChain returns name followed by the profiles it extends, nearest first
+func (c *Config) extendsChain(name string) ([]string, error) {
+	if _, ok := c.Profiles[name]; !ok {
+		return nil, fmt.Errorf("profile '%s' not found", name)
+	}
+
+	chain := []string{name}
+	seen := map[string]bool{name: true}
+	for current := name; c.Profiles[current].Extends != ""; {
+		parent := c.Profiles[current].Extends
+		if _, ok := c.Profiles[parent]; !ok {
+			return nil, fmt.Errorf("profile '%s' extends '%s', which does not exist", current, parent)
+		}
+		if seen[parent] {
+			return nil, fmt.Errorf("extends cycle: %s -> %s", strings.Join(chain, " -> "), parent)
+		}
+		chain = append(chain, parent)
+		seen[parent] = true
+		current = parent
+	}
+	return chain, nil
+}
+
+// checkExtends makes sure every profile's extends chain resolves
+func (c *Config) checkExtends() error {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if _, err := c.extendsChain(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// overlay copies the fields src sets onto dst, recording source as their origin
+func overlay(dst *Profile, src Profile, source string, sources map[string]string) {
+	dv := reflect.ValueOf(dst).Elem()
+	sv := reflect.ValueOf(src)
+	t := sv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if !t.Field(i).IsExported() {
+			continue
+		}
+		name := jsonName(t.Field(i))
+		field := sv.Field(i)
+		if name == "extends" || !src.sets(name, field) {
+			continue
+		}
+
+		if field.Kind() != reflect.Map {
+			dv.Field(i).Set(field)
+			sources[name] = source
+			continue
+		}
+
+		// Settings merge all the way down
+		if settings, ok := field.Interface().(map[string]interface{}); ok {
+			merged := make(map[string]interface{})
+			for key, value := range dv.Field(i).Interface().(map[string]interface{}) {
+				merged[key] = value
+			}
+			mergeSettings(merged, settings, name+".", source, sources)
+			dv.Field(i).Set(reflect.ValueOf(merged))
+			continue
+		}
+
+		// Copy before merging so parents' maps are left untouched
+		merged := reflect.MakeMap(field.Type())
+		if existing := dv.Field(i); !existing.IsNil() {
+			for _, key := range existing.MapKeys() {
+				merged.SetMapIndex(key, existing.MapIndex(key))
+			}
+		}
+		for _, key := range field.MapKeys() {
+			merged.SetMapIndex(key, field.MapIndex(key))
+			sources[name+"."+key.String()] = source
+		}
+		dv.Field(i).Set(merged)
+	}
+}
+
+// sets reports whether the profile sets the named field. Profiles read from
+// the config file set exactly the fields written there, empty ones included;
+// others set their non-zero fields. Empty maps add nothing to merge.
+func (p *Profile) sets(name string, field reflect.Value) bool {
+	if field.Kind() == reflect.Map {
+		return field.Len() > 0
+	}
+	if p.present != nil {
+		return p.present[name]
+	}
+	return !field.IsZero()
+}
+
+// UnmarshalJSON decodes a profile, recording which fields are present
+func (p *Profile) UnmarshalJSON(data []byte) error {
+	type plain Profile
+	if err := json.Unmarshal(data, (*plain)(p)); err != nil {
+		return err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	p.present = make(map[string]bool, len(fields))
+	for name := range fields {
+		p.present[name] = true
+	}
+	return nil
+}
+
+// MarshalJSON encodes a profile like encoding/json would, except that
+// fields present in the config file are kept even when empty, so an
+// explicit override of an inherited value survives a save
+func (p Profile) MarshalJSON() ([]byte, error) {
+	v := reflect.ValueOf(p)
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for _, f := range jsonFields(v.Type()) {
+		field := v.FieldByIndex(f.index)
+		if f.omitEmpty && isEmptyValue(field) && !p.present[f.name] {
+			continue
+		}
+		value, err := json.Marshal(field.Interface())
+		if err != nil {
+			return nil, err
+		}
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(f.name)
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// isEmptyValue matches encoding/json's notion of empty for omitempty
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.String, reflect.Map, reflect.Slice, reflect.Array:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
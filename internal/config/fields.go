@@ -0,0 +1,232 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+)
+
+// SetProfileValue sets the field at a dotted path such as "timeout_ms",
+// "models.haiku" or "settings.statusLine.command" in the named profile.
+// value is taken as is for string fields; for other fields it is parsed as
+// JSON, falling back to a string, so api_key accepts both "sk-..." and
+// ["sk-1","sk-2"].
+func (c *Config) SetProfileValue(name, path, value string) error {
+	if _, ok := c.Profiles[name]; !ok {
+		return fmt.Errorf("profile '%s' not found", name)
+	}
+	keys, err := splitPath(path)
+	if err != nil {
+		return err
+	}
+	t, err := fieldType(keys)
+	if err != nil {
+		return err
+	}
+
+	var parsed interface{} = value
+	if t.Kind() != reflect.String {
+		var v interface{}
+		if json.Unmarshal([]byte(value), &v) == nil {
+			parsed = v
+		}
+	}
+
+	if err := validateValue(keys, parsed); err != nil {
+		return err
+	}
+
+	return c.editProfile(name, path, func(raw map[string]interface{}) error {
+		obj := raw
+		for _, key := range keys[:len(keys)-1] {
+			child, ok := obj[key].(map[string]interface{})
+			if !ok {
+				child = make(map[string]interface{})
+				obj[key] = child
+			}
+			obj = child
+		}
+		obj[keys[len(keys)-1]] = parsed
+		return nil
+	})
+}
+
+// UnsetProfileValue removes the field at a dotted path from the named profile
+func (c *Config) UnsetProfileValue(name, path string) error {
+	if _, ok := c.Profiles[name]; !ok {
+		return fmt.Errorf("profile '%s' not found", name)
+	}
+	keys, err := splitPath(path)
+	if err != nil {
+		return err
+	}
+	if _, err := fieldType(keys); err != nil {
+		return err
+	}
+
+	return c.editProfile(name, path, func(raw map[string]interface{}) error {
+		obj := raw
+		for _, key := range keys[:len(keys)-1] {
+			child, ok := obj[key].(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("profile '%s' has no %s", name, path)
+			}
+			obj = child
+		}
+		last := keys[len(keys)-1]
+		if _, ok := obj[last]; !ok {
+			return fmt.Errorf("profile '%s' has no %s", name, path)
+		}
+		delete(obj, last)
+		return nil
+	})
+}
+
+// editProfile round-trips the named, existing profile through its JSON form
+// so fn can edit it by path, then decodes the result back into the config
+func (c *Config) editProfile(name, path string, fn func(raw map[string]interface{}) error) error {
+	data, err := json.Marshal(c.Profiles[name])
+	if err != nil {
+		return fmt.Errorf("failed to marshal profile: %w", err)
+	}
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("failed to marshal profile: %w", err)
+	}
+
+	if err := fn(raw); err != nil {
+		return err
+	}
+
+	if data, err = json.Marshal(raw); err != nil {
+		return fmt.Errorf("failed to marshal profile: %w", err)
+	}
+	var edited Profile
+	if err := json.Unmarshal(data, &edited); err != nil {
+		return fmt.Errorf("invalid value for %s: %w", path, err)
+	}
+	c.Profiles[name] = edited
+	return nil
+}
+
+// splitPath splits a dotted profile path into its keys
+func splitPath(path string) ([]string, error) {
+	keys := strings.Split(path, ".")
+	for _, key := range keys {
+		if key == "" {
+			return nil, fmt.Errorf("invalid path %q", path)
+		}
+	}
+	return keys, nil
+}
+
+// fieldType returns the Go type a profile path leads to, rejecting paths
+// through unknown fields or into values that aren't objects
+func fieldType(keys []string) (reflect.Type, error) {
+	t := reflect.TypeOf(Profile{})
+	for i, key := range keys {
+		switch t.Kind() {
+		case reflect.Struct:
+			fields := make(map[string]jsonField)
+			for _, f := range jsonFields(t) {
+				fields[f.name] = f
+			}
+			f, ok := fields[key]
+			if !ok {
+				msg := fmt.Sprintf("unknown field %q", key)
+				if s := suggestField(key, fields); s != "" {
+					msg += fmt.Sprintf(" (did you mean %q?)", s)
+				}
+				return nil, fmt.Errorf("%s", msg)
+			}
+			t = f.typ
+		case reflect.Map:
+			t = t.Elem()
+		case reflect.Interface:
+			// Settings take any JSON below their top level
+		default:
+			return nil, fmt.Errorf("%s is not an object", strings.Join(keys[:i], "."))
+		}
+	}
+	return t, nil
+}
+
+// ValidateProfileValue checks a value for the field at a dotted path the
+// way SetProfileValue does, for callers that build profiles themselves
+func ValidateProfileValue(path string, value interface{}) error {
+	keys, err := splitPath(path)
+	if err != nil {
+		return err
+	}
+	return validateValue(keys, value)
+}
+
+// validateValue checks the values JSON decoding can't: enum fields, model
+// slots, names used as env vars or headers, literal base URLs and timeouts
+func validateValue(keys []string, value interface{}) error {
+	field := keys[0]
+	switch field {
+	case "base_url":
+		if s, ok := value.(string); ok && s != "" && !strings.Contains(s, "$") {
+			if err := ValidateBaseURL(s); err != nil {
+				return fmt.Errorf("base_url %q is malformed: %v", s, err)
+			}
+		}
+	case "timeout_ms":
+		// Numbers parsed from JSON are float64; callers may pass an int
+		n, ok := value.(float64)
+		if i, isInt := value.(int); isInt {
+			n, ok = float64(i), true
+		}
+		if ok && n < 0 {
+			return fmt.Errorf("timeout_ms must not be negative")
+		}
+	}
+
+	for _, f := range jsonFields(reflect.TypeOf(Profile{})) {
+		if f.name != field || f.enum == "" {
+			continue
+		}
+		allowed := strings.Split(f.enum, ",")
+		for _, a := range allowed {
+			if value == a {
+				return nil
+			}
+		}
+		return fmt.Errorf("invalid %s %v (expected %s)", field, value, strings.Join(allowed, ", "))
+	}
+
+	if len(keys) < 2 {
+		return nil
+	}
+	switch field {
+	case "models":
+		return ValidateModelSlot(keys[1])
+	case "env":
+		return ValidateEnvName(keys[1])
+	case "headers":
+		return ValidateHeaderName(keys[1])
+	case "settings":
+		if keys[1] == "env" {
+			return fmt.Errorf("settings.env: use the profile's env field instead")
+		}
+	}
+	return nil
+}
+
+// ValidateBaseURL checks that a base URL is an absolute http(s) URL
+func ValidateBaseURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("scheme must be http or https")
+	}
+	if u.Host == "" {
+		return fmt.Errorf("missing host")
+	}
+	return nil
+}
@@ -0,0 +1,225 @@
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testConfig builds a config from the JSON of its profiles
+func testConfig(t *testing.T, profiles string) *Config {
+	t.Helper()
+	cfg := &Config{}
+	if err := json.Unmarshal([]byte(`{"profiles": `+profiles+`}`), cfg); err != nil {
+		t.Fatalf("bad test config %s: %v", profiles, err)
+	}
+	return cfg
+}
+
+// profileJSON returns the named profile as it would be saved
+func profileJSON(t *testing.T, cfg *Config, name string) string {
+	t.Helper()
+	data, err := json.Marshal(cfg.Profiles[name])
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	return string(data)
+}
+
+func TestSetProfileValue(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile string
+		path    string
+		value   string
+		want    string // the profile as saved
+		wantErr string
+	}{
+		{
+			name:    "string field",
+			profile: `{"display_name": "A"}`,
+			path:    "base_url", value: "https://api.example.com",
+			want: `{"display_name":"A","base_url":"https://api.example.com"}`,
+		},
+		{
+			name:    "string field is not parsed as JSON",
+			profile: `{"display_name": "A"}`,
+			path:    "display_name", value: `["x"]`,
+			want: `{"display_name":"[\"x\"]"}`,
+		},
+		{
+			name:    "number field",
+			profile: `{"display_name": "A"}`,
+			path:    "timeout_ms", value: "600000",
+			want: `{"display_name":"A","timeout_ms":600000}`,
+		},
+		{
+			name:    "single key",
+			profile: `{"display_name": "A"}`,
+			path:    "api_key", value: "sk-1",
+			want: `{"display_name":"A","api_key":"sk-1"}`,
+		},
+		{
+			name:    "key list",
+			profile: `{"display_name": "A"}`,
+			path:    "api_key", value: `["sk-1","sk-2"]`,
+			want: `{"display_name":"A","api_key":["sk-1","sk-2"]}`,
+		},
+		{
+			name:    "map entry",
+			profile: `{"display_name": "A", "models": {"opus": "o"}}`,
+			path:    "models.haiku", value: "h",
+			want: `{"display_name":"A","models":{"haiku":"h","opus":"o"}}`,
+		},
+		{
+			name:    "nested setting creates objects",
+			profile: `{"display_name": "A"}`,
+			path:    "settings.statusLine.command", value: "echo hi",
+			want: `{"display_name":"A","settings":{"statusLine":{"command":"echo hi"}}}`,
+		},
+		{
+			name:    "setting parsed as JSON",
+			profile: `{"display_name": "A"}`,
+			path:    "settings.includeCoAuthoredBy", value: "false",
+			want: `{"display_name":"A","settings":{"includeCoAuthoredBy":false}}`,
+		},
+		{
+			name:    "explicit empty override is kept",
+			profile: `{"display_name": "A", "extends": "base", "base_url": ""}`,
+			path:    "timeout_ms", value: "1000",
+			want: `{"display_name":"A","extends":"base","base_url":"","timeout_ms":1000}`,
+		},
+
+		{name: "empty path segment", profile: `{}`, path: "models..opus", value: "x", wantErr: `invalid path "models..opus"`},
+		{name: "unknown field", profile: `{}`, path: "base_ur", value: "x", wantErr: `unknown field "base_ur" (did you mean "base_url"?)`},
+		{name: "path into a string", profile: `{}`, path: "base_url.x", value: "x", wantErr: "base_url is not an object"},
+		{name: "enum", profile: `{}`, path: "auth_type", value: "basic", wantErr: "invalid auth_type basic"},
+		{name: "negative timeout", profile: `{}`, path: "timeout_ms", value: "-1", wantErr: "timeout_ms must not be negative"},
+		{name: "wrong type", profile: `{}`, path: "timeout_ms", value: "soon", wantErr: "invalid value for timeout_ms"},
+		{name: "malformed base URL", profile: `{}`, path: "base_url", value: "api.example.com", wantErr: "is malformed"},
+		{name: "bad env name", profile: `{}`, path: "env.MY-VAR", value: "x", wantErr: `invalid variable name "MY-VAR"`},
+		{name: "settings.env", profile: `{}`, path: "settings.env.A", value: "x", wantErr: "use the profile's env field"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig(t, `{"p": `+tt.profile+`}`)
+			err := cfg.SetProfileValue("p", tt.path, tt.value)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got %v, want error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SetProfileValue: %v", err)
+			}
+			if got := profileJSON(t, cfg, "p"); got != tt.want {
+				t.Errorf("got  %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+
+	cfg := testConfig(t, `{}`)
+	if err := cfg.SetProfileValue("p", "base_url", "x"); err == nil || err.Error() != "profile 'p' not found" {
+		t.Errorf("got %v, want profile 'p' not found", err)
+	}
+	if err := cfg.UnsetProfileValue("p", "base_url"); err == nil || err.Error() != "profile 'p' not found" {
+		t.Errorf("got %v, want profile 'p' not found", err)
+	}
+}
+
+func TestUnsetProfileValue(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile string
+		path    string
+		want    string
+		wantErr string
+	}{
+		{
+			name:    "field",
+			profile: `{"display_name": "A", "timeout_ms": 1000}`,
+			path:    "timeout_ms",
+			want:    `{"display_name":"A"}`,
+		},
+		{
+			name:    "map entry",
+			profile: `{"display_name": "A", "models": {"opus": "o", "haiku": "h"}}`,
+			path:    "models.haiku",
+			want:    `{"display_name":"A","models":{"opus":"o"}}`,
+		},
+		{
+			name:    "nested setting",
+			profile: `{"display_name": "A", "settings": {"statusLine": {"type": "command", "command": "x"}}}`,
+			path:    "settings.statusLine.command",
+			want:    `{"display_name":"A","settings":{"statusLine":{"type":"command"}}}`,
+		},
+		{
+			name:    "explicit empty override",
+			profile: `{"display_name": "A", "extends": "base", "base_url": ""}`,
+			path:    "base_url",
+			want:    `{"display_name":"A","extends":"base"}`,
+		},
+
+		{name: "missing field", profile: `{"display_name": "A"}`, path: "base_url", wantErr: "profile 'p' has no base_url"},
+		{name: "missing map entry", profile: `{"display_name": "A"}`, path: "models.opus", wantErr: "profile 'p' has no models.opus"},
+		{name: "unknown field", profile: `{"display_name": "A"}`, path: "modles.opus", wantErr: `unknown field "modles"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig(t, `{"p": `+tt.profile+`}`)
+			err := cfg.UnsetProfileValue("p", tt.path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got %v, want error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("UnsetProfileValue: %v", err)
+			}
+			if got := profileJSON(t, cfg, "p"); got != tt.want {
+				t.Errorf("got  %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestEditKeepsPresentFields checks that an empty override set in the file
+// still shadows the inherited value after set and unset round-trip the
+// profile, and that unsetting it brings the inherited value back
+func TestEditKeepsPresentFields(t *testing.T) {
+	cfg := testConfig(t, `{
+		"base": {"display_name": "Base", "base_url": "https://base.example.com", "models": {"opus": "o"}},
+		"child": {"display_name": "Child", "extends": "base", "base_url": ""}
+	}`)
+
+	if err := cfg.SetProfileValue("child", "models.haiku", "h"); err != nil {
+		t.Fatalf("SetProfileValue: %v", err)
+	}
+	resolved, sources, err := cfg.ResolveProfile("child")
+	if err != nil {
+		t.Fatalf("ResolveProfile: %v", err)
+	}
+	if resolved.BaseURL != "" || sources["base_url"] != "child" {
+		t.Errorf("base_url = %q from %q, want the empty override from child", resolved.BaseURL, sources["base_url"])
+	}
+	wantModels := map[string]string{"opus": "o", "haiku": "h"}
+	if !reflect.DeepEqual(resolved.Models, wantModels) {
+		t.Errorf("models = %v, want %v", resolved.Models, wantModels)
+	}
+
+	if err := cfg.UnsetProfileValue("child", "base_url"); err != nil {
+		t.Fatalf("UnsetProfileValue: %v", err)
+	}
+	resolved, sources, err = cfg.ResolveProfile("child")
+	if err != nil {
+		t.Fatalf("ResolveProfile: %v", err)
+	}
+	if resolved.BaseURL != "https://base.example.com" || sources["base_url"] != "base" {
+		t.Errorf("base_url = %q from %q, want it inherited from base", resolved.BaseURL, sources["base_url"])
+	}
+}
@@ -0,0 +1,276 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"time"
+
+	"github.com/nanmi/cc-portkey/internal/fileutil"
+)
+
+// migration upgrades a raw config document from version From to From+1.
+// Migrations work on the decoded JSON rather than on Config so they keep
+// working after the structs have moved on.
+type migration struct {
+	From        int
+	Description string
+	Apply       func(raw map[string]interface{}) error
+}
+
+// migrations is the registry of upgrade steps, in order. Append new steps
+// here; CurrentVersion follows automatically.
+var migrations = []migration{
+	{
+		From:        1,
+		Description: "add schema version field",
+		Apply:       func(raw map[string]interface{}) error { return nil },
+	},
+	{
+		From:        2,
+		Description: "make ${VAR} base URLs optional now that unset variables are errors",
+		Apply:       optionalBaseURLs,
+	},
+	{
+		From:        3,
+		Description: "move the built-in shortcuts ccc, ds, glm and mm into an aliases section",
+		Apply:       addDefaultAliases,
+	},
+	{
+		From:        4,
+		Description: "turn the untouched default claude profile into a subscription profile",
+		Apply:       subscriptionDefault,
+	},
+}
+
+// bareRefPattern matches a value that is nothing but a ${VAR} reference
+var bareRefPattern = regexp.MustCompile(`^\$\{([A-Za-z_][A-Za-z0-9_]*)\}$`)
+
+// optionalBaseURLs rewrites base URLs of the form ${VAR} to ${VAR:-}.
+// Before version 3 an unset or empty variable was left in the value as the
+// literal text ${VAR}; a plain ${VAR} now fails instead, so base URLs that
+// were meant to fall back to the official API say so with ${VAR:-}.
+func optionalBaseURLs(raw map[string]interface{}) error {
+	profiles, _ := raw["profiles"].(map[string]interface{})
+	for _, p := range profiles {
+		profile, ok := p.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if baseURL, ok := profile["base_url"].(string); ok {
+			profile["base_url"] = bareRefPattern.ReplaceAllString(baseURL, "$${${1}:-}")
+		}
+	}
+	return nil
+}
+
+// addDefaultAliases writes out the shortcuts that were compiled in before
+// version 4, so existing symlinks keep working
+func addDefaultAliases(raw map[string]interface{}) error {
+	if _, ok := raw["aliases"]; ok {
+		return nil
+	}
+	raw["aliases"] = map[string]interface{}{
+		"ccc": map[string]interface{}{"profile": "claude"},
+		"ds":  map[string]interface{}{"profile": "deepseek"},
+		"glm": map[string]interface{}{"profile": "glm"},
+		"mm":  map[string]interface{}{"profile": "minimax"},
+	}
+	return nil
+}
+
+// subscriptionDefault converts the claude profile init used to write, which
+// read ${ANTHROPIC_API_KEY} and now fails when that is unset, to the
+// subscription kind that replaced it. Profiles changed in any way are kept.
+func subscriptionDefault(raw map[string]interface{}) error {
+	profiles, _ := raw["profiles"].(map[string]interface{})
+	profile, ok := profiles["claude"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	for key, value := range profile {
+		switch key {
+		case "display_name", "timeout_ms":
+		case "base_url":
+			if value != "${ANTHROPIC_BASE_URL}" && value != "${ANTHROPIC_BASE_URL:-}" {
+				return nil
+			}
+		case "api_key":
+			list, isList := value.([]interface{})
+			if value != "${ANTHROPIC_API_KEY}" && !(isList && len(list) == 1 && list[0] == "${ANTHROPIC_API_KEY}") {
+				return nil
+			}
+		case "models":
+			if models, ok := value.(map[string]interface{}); !ok || len(models) > 0 {
+				return nil
+			}
+		default:
+			return nil
+		}
+	}
+	if _, ok := profile["api_key"]; !ok {
+		return nil
+	}
+
+	delete(profile, "base_url")
+	delete(profile, "api_key")
+	profile["kind"] = "subscription"
+	return nil
+}
+
+// CurrentVersion is the config schema version written by this build.
+// Files without a version field are version 1.
+var CurrentVersion = len(migrations) + 1
+
+// MigrationPlan describes the migrations needed to bring a config file
+// up to CurrentVersion
+type MigrationPlan struct {
+	Path   string
+	From   int
+	To     int
+	Steps  []string // descriptions of the migrations to run, in order
+	Before []byte   // file contents before migrating
+	After  []byte   // file contents after migrating
+	Backup string   // where the pre-migration file was saved, once migrated
+}
+
+// Pending reports whether any migrations need to run
+func (p *MigrationPlan) Pending() bool {
+	return len(p.Steps) > 0
+}
+
+// PlanMigration reports what migrating the config file would change
+// without writing anything
+func PlanMigration() (*MigrationPlan, error) {
+	path, err := ConfigPath()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := readConfig(path)
+	if err != nil {
+		return nil, err
+	}
+
+	_, plan, err := decode(data)
+	if err != nil {
+		return nil, err
+	}
+	plan.Path = path
+	return plan, nil
+}
+
+// Migrate upgrades the config file to CurrentVersion, saving a backup of
+// the original first. It is a no-op for files that are already current.
+func Migrate() (*MigrationPlan, error) {
+	path, err := ConfigPath()
+	if err != nil {
+		return nil, err
+	}
+
+	var plan *MigrationPlan
+	err = fileutil.WithLock(lockPath(path), func() error {
+		_, plan, err = loadLocked(path)
+		return err
+	})
+	return plan, err
+}
+
+// decode parses config file contents, running any pending migrations in memory
+func decode(data []byte) (*Config, *MigrationPlan, error) {
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+	if raw == nil {
+		return nil, nil, fmt.Errorf("failed to parse config file: top-level value is not an object")
+	}
+
+	version := 1
+	if v, ok := raw["version"]; ok {
+		n, ok := v.(float64)
+		if !ok || n < 1 || n != float64(int(n)) {
+			return nil, nil, fmt.Errorf("invalid config version %v", v)
+		}
+		version = int(n)
+	}
+	if version > CurrentVersion {
+		return nil, nil, fmt.Errorf("config file version %d is newer than this cc-portkey supports (%d); please upgrade cc-portkey", version, CurrentVersion)
+	}
+
+	plan := &MigrationPlan{From: version, To: CurrentVersion, Before: data}
+	for _, m := range migrations {
+		if m.From < version {
+			continue
+		}
+		if err := m.Apply(raw); err != nil {
+			return nil, nil, fmt.Errorf("failed to migrate config from version %d to %d: %w", m.From, m.From+1, err)
+		}
+		plan.Steps = append(plan.Steps, fmt.Sprintf("v%d -> v%d: %s", m.From, m.From+1, m.Description))
+	}
+	raw["version"] = CurrentVersion
+
+	migrated, err := json.Marshal(raw)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal migrated config: %w", err)
+	}
+
+	var cfg Config
+	if err := json.Unmarshal(migrated, &cfg); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+
+	// Report unknown fields against the file as written when possible, so
+	// line and column numbers point into the user's file
+	checked := data
+	if len(plan.Steps) > 0 {
+		if checked, err = json.MarshalIndent(raw, "", "  "); err != nil {
+			return nil, nil, fmt.Errorf("failed to marshal migrated config: %w", err)
+		}
+	}
+	if err := checkUnknownFields(checked, reflect.TypeOf(Config{})); err != nil {
+		return nil, nil, fmt.Errorf("invalid config file:\n%w", err)
+	}
+	if err := cfg.checkExtends(); err != nil {
+		return nil, nil, fmt.Errorf("invalid config file: %w", err)
+	}
+
+	plan.After = data
+	if plan.Pending() {
+		if plan.After, err = marshal(&cfg); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return &cfg, plan, nil
+}
+
+// loadLocked reads the config file and persists any pending migration,
+// keeping a backup of the original. The caller must hold the lock.
+func loadLocked(path string) (*Config, *MigrationPlan, error) {
+	data, err := readConfig(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cfg, plan, err := decode(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	plan.Path = path
+
+	if plan.Pending() {
+		plan.Backup = fmt.Sprintf("%s.v%d-%s.bak", path, plan.From, time.Now().Format("20060102-150405"))
+		if err := os.WriteFile(plan.Backup, data, 0600); err != nil {
+			return nil, nil, fmt.Errorf("failed to back up config before migrating: %w", err)
+		}
+		if err := save(path, cfg); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return cfg, plan, nil
+}
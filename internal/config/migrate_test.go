@@ -0,0 +1,200 @@
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// rawJSON decodes a JSON object the way decode hands it to migrations
+func rawJSON(t *testing.T, s string) map[string]interface{} {
+	t.Helper()
+	var raw map[string]interface{}
+	if err := json.Unmarshal([]byte(s), &raw); err != nil {
+		t.Fatalf("bad test JSON %s: %v", s, err)
+	}
+	return raw
+}
+
+func TestMigrations(t *testing.T) {
+	tests := []struct {
+		name  string
+		apply func(raw map[string]interface{}) error
+		input string
+		want  string
+	}{
+		{
+			name:  "v2: bare base URL becomes optional",
+			apply: optionalBaseURLs,
+			input: `{"profiles": {"a": {"base_url": "${ANTHROPIC_BASE_URL}"}}}`,
+			want:  `{"profiles": {"a": {"base_url": "${ANTHROPIC_BASE_URL:-}"}}}`,
+		},
+		{
+			name:  "v2: other base URLs are kept",
+			apply: optionalBaseURLs,
+			input: `{"profiles": {
+				"a": {"base_url": "https://${HOST}/v1"},
+				"b": {"base_url": "https://api.deepseek.com/anthropic"},
+				"c": {"base_url": "${URL:-https://x}"},
+				"d": {"api_key": "${KEY}"}
+			}}`,
+			want: `{"profiles": {
+				"a": {"base_url": "https://${HOST}/v1"},
+				"b": {"base_url": "https://api.deepseek.com/anthropic"},
+				"c": {"base_url": "${URL:-https://x}"},
+				"d": {"api_key": "${KEY}"}
+			}}`,
+		},
+		{
+			name:  "v3: built-in aliases are written out",
+			apply: addDefaultAliases,
+			input: `{"profiles": {}}`,
+			want: `{"profiles": {}, "aliases": {
+				"ccc": {"profile": "claude"},
+				"ds": {"profile": "deepseek"},
+				"glm": {"profile": "glm"},
+				"mm": {"profile": "minimax"}
+			}}`,
+		},
+		{
+			name:  "v3: existing aliases are kept",
+			apply: addDefaultAliases,
+			input: `{"aliases": {"work": {"profile": "glm"}}}`,
+			want:  `{"aliases": {"work": {"profile": "glm"}}}`,
+		},
+		{
+			name:  "v4: default claude profile becomes a subscription",
+			apply: subscriptionDefault,
+			input: `{"profiles": {"claude": {
+				"display_name": "Claude",
+				"base_url": "${ANTHROPIC_BASE_URL:-}",
+				"api_key": "${ANTHROPIC_API_KEY}",
+				"models": {}
+			}}}`,
+			want: `{"profiles": {"claude": {
+				"display_name": "Claude",
+				"kind": "subscription",
+				"models": {}
+			}}}`,
+		},
+		{
+			name:  "v4: single-key list and v1 base URL",
+			apply: subscriptionDefault,
+			input: `{"profiles": {"claude": {"base_url": "${ANTHROPIC_BASE_URL}", "api_key": ["${ANTHROPIC_API_KEY}"]}}}`,
+			want:  `{"profiles": {"claude": {"kind": "subscription"}}}`,
+		},
+		{
+			name:  "v4: changed key is kept",
+			apply: subscriptionDefault,
+			input: `{"profiles": {"claude": {"api_key": "${CLAUDE_WORK_KEY}"}}}`,
+			want:  `{"profiles": {"claude": {"api_key": "${CLAUDE_WORK_KEY}"}}}`,
+		},
+		{
+			name:  "v4: models set are kept",
+			apply: subscriptionDefault,
+			input: `{"profiles": {"claude": {"api_key": "${ANTHROPIC_API_KEY}", "models": {"default": "opus"}}}}`,
+			want:  `{"profiles": {"claude": {"api_key": "${ANTHROPIC_API_KEY}", "models": {"default": "opus"}}}}`,
+		},
+		{
+			name:  "v4: extra fields are kept",
+			apply: subscriptionDefault,
+			input: `{"profiles": {"claude": {"api_key": "${ANTHROPIC_API_KEY}", "env": {"HTTPS_PROXY": "http://p"}}}}`,
+			want:  `{"profiles": {"claude": {"api_key": "${ANTHROPIC_API_KEY}", "env": {"HTTPS_PROXY": "http://p"}}}}`,
+		},
+		{
+			name:  "v4: profile without a key is kept",
+			apply: subscriptionDefault,
+			input: `{"profiles": {"claude": {"display_name": "Claude"}}}`,
+			want:  `{"profiles": {"claude": {"display_name": "Claude"}}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw := rawJSON(t, tt.input)
+			if err := tt.apply(raw); err != nil {
+				t.Fatalf("apply: %v", err)
+			}
+			if want := rawJSON(t, tt.want); !reflect.DeepEqual(raw, want) {
+				got, _ := json.Marshal(raw)
+				t.Errorf("got %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeVersions(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		wantFrom  int
+		wantSteps int
+		wantErr   string
+	}{
+		{name: "no version is v1", input: `{"profiles": {}}`, wantFrom: 1, wantSteps: CurrentVersion - 1},
+		{name: "v3 runs the later steps", input: `{"version": 3, "profiles": {}}`, wantFrom: 3, wantSteps: CurrentVersion - 3},
+		{name: "current", input: `{"version": ` + strconv.Itoa(CurrentVersion) + `, "profiles": {}}`, wantFrom: CurrentVersion},
+		{
+			name:    "newer than this build",
+			input:   `{"version": ` + strconv.Itoa(CurrentVersion+1) + `}`,
+			wantErr: "is newer than this cc-portkey supports",
+		},
+		{name: "fractional version", input: `{"version": 1.5}`, wantErr: "invalid config version 1.5"},
+		{name: "zero version", input: `{"version": 0}`, wantErr: "invalid config version 0"},
+		{name: "string version", input: `{"version": "2"}`, wantErr: "invalid config version 2"},
+		{name: "not an object", input: `[]`, wantErr: "failed to parse config file"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, plan, err := decode([]byte(tt.input))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got %v, want error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if plan.From != tt.wantFrom || plan.To != CurrentVersion {
+				t.Errorf("plan goes from v%d to v%d, want v%d to v%d", plan.From, plan.To, tt.wantFrom, CurrentVersion)
+			}
+			if len(plan.Steps) != tt.wantSteps {
+				t.Errorf("got %d steps %v, want %d", len(plan.Steps), plan.Steps, tt.wantSteps)
+			}
+			if cfg.Version != CurrentVersion {
+				t.Errorf("migrated config has version %d, want %d", cfg.Version, CurrentVersion)
+			}
+			if !plan.Pending() && string(plan.After) != tt.input {
+				t.Errorf("a current file was rewritten:\n%s", plan.After)
+			}
+		})
+	}
+}
+
+func TestDecodeMigratesV1(t *testing.T) {
+	input := `{
+		"current": "claude",
+		"profiles": {
+			"claude": {"display_name": "Claude", "base_url": "${ANTHROPIC_BASE_URL}", "api_key": "${ANTHROPIC_API_KEY}"},
+			"work": {"base_url": "${WORK_URL}", "api_key": "${WORK_KEY}"}
+		}
+	}`
+	cfg, _, err := decode([]byte(input))
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+
+	if claude := cfg.Profiles["claude"]; !claude.IsSubscription() || claude.BaseURL != "" || claude.KeyCount() != 0 {
+		t.Errorf("claude profile = %+v, want a subscription profile", claude)
+	}
+	if work := cfg.Profiles["work"]; work.BaseURL != "${WORK_URL:-}" {
+		t.Errorf("work base_url = %q, want ${WORK_URL:-}", work.BaseURL)
+	}
+	if alias, ok := cfg.Aliases["ds"]; !ok || alias.Profile != "deepseek" {
+		t.Errorf("aliases = %v, want the built-in shortcuts", cfg.Aliases)
+	}
+}
@@ -0,0 +1,119 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ModelSlot is a key of a profile's models and the env var it sets
+type ModelSlot struct {
+	Key    string
+	EnvVar string
+	Doc    string
+}
+
+// ModelSlots lists the keys understood in a profile's models, in display order
+var ModelSlots = []ModelSlot{
+	{Key: "default", EnvVar: "ANTHROPIC_MODEL", Doc: "Main model"},
+	{Key: "opus", EnvVar: "ANTHROPIC_DEFAULT_OPUS_MODEL", Doc: "Model used when Claude Code asks for opus"},
+	{Key: "sonnet", EnvVar: "ANTHROPIC_DEFAULT_SONNET_MODEL", Doc: "Model used when Claude Code asks for sonnet"},
+	{Key: "haiku", EnvVar: "ANTHROPIC_DEFAULT_HAIKU_MODEL", Doc: "Model used when Claude Code asks for haiku, and for background tasks"},
+	{Key: "subagent", EnvVar: "CLAUDE_CODE_SUBAGENT_MODEL", Doc: "Model for subagents"},
+}
+
+// ModelSlotAliases maps deprecated model keys to the slot that replaced them.
+// A slot set directly wins over its aliases.
+var ModelSlotAliases = map[string]string{
+	"small_fast": "haiku", // ANTHROPIC_SMALL_FAST_MODEL gave way to the haiku model
+}
+
+// ModelSlotFor returns the slot a models key sets, following aliases
+func ModelSlotFor(key string) (ModelSlot, bool) {
+	if target, ok := ModelSlotAliases[key]; ok {
+		key = target
+	}
+	for _, slot := range ModelSlots {
+		if slot.Key == key {
+			return slot, true
+		}
+	}
+	return ModelSlot{}, false
+}
+
+// ValidateModelSlot checks that key names a model slot, rejecting
+// deprecated aliases so new configs use the current names
+func ValidateModelSlot(key string) error {
+	if target, ok := ModelSlotAliases[key]; ok {
+		return fmt.Errorf("model slot %q is deprecated; use %s", key, target)
+	}
+	if _, ok := ModelSlotFor(key); ok {
+		return nil
+	}
+	msg := fmt.Sprintf("unknown model slot %q", key)
+	if s := suggestModelSlot(key); s != "" {
+		msg += fmt.Sprintf(" (did you mean %q?)", s)
+	}
+	return fmt.Errorf("%s", msg)
+}
+
+// ModelEnv returns the env vars set by the profile's models.
+// Empty model names and unknown keys set nothing.
+func (p *Profile) ModelEnv() map[string]string {
+	env := make(map[string]string)
+
+	// Aliases first, so slots set directly overwrite them
+	for key, model := range p.Models {
+		if _, ok := ModelSlotAliases[key]; !ok || model == "" {
+			continue
+		}
+		if slot, ok := ModelSlotFor(key); ok {
+			env[slot.EnvVar] = model
+		}
+	}
+	for _, slot := range ModelSlots {
+		if model := p.Models[slot.Key]; model != "" {
+			env[slot.EnvVar] = model
+		}
+	}
+	return env
+}
+
+// ModelWarnings describes the keys of the profile's models that set nothing
+// or use a deprecated name, sorted by key
+func (p *Profile) ModelWarnings() []string {
+	keys := make([]string, 0, len(p.Models))
+	for key := range p.Models {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var warnings []string
+	for _, key := range keys {
+		if target, ok := ModelSlotAliases[key]; ok {
+			if p.Models[target] != "" {
+				warnings = append(warnings, fmt.Sprintf("models.%s is ignored because models.%s is set", key, target))
+			} else {
+				warnings = append(warnings, fmt.Sprintf("models.%s is deprecated; rename it to %s", key, target))
+			}
+			continue
+		}
+		if _, ok := ModelSlotFor(key); ok {
+			continue
+		}
+		msg := fmt.Sprintf("models.%s is not a model slot and is ignored", key)
+		if s := suggestModelSlot(key); s != "" {
+			msg += fmt.Sprintf(" (did you mean %q?)", s)
+		}
+		warnings = append(warnings, msg)
+	}
+	return warnings
+}
+
+// suggestModelSlot returns the slot key closest to key, if any is close enough
+func suggestModelSlot(key string) string {
+	fields := make(map[string]jsonField, len(ModelSlots))
+	for _, slot := range ModelSlots {
+		fields[slot.Key] = jsonField{name: slot.Key}
+	}
+	return suggestField(key, fields)
+}
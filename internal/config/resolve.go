@@ -0,0 +1,171 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// CommandTimeout bounds how long api_key_cmd and base_url_cmd may run
+const CommandTimeout = 30 * time.Second
+
+// Resolved holds the values a profile actually uses once references are
+// expanded and commands have run
+type Resolved struct {
+	BaseURL string
+	APIKey  string
+	Headers map[string]string
+	Env     map[string]string
+}
+
+// Resolve computes the profile's effective base URL and API key.
+// A *_cmd field takes the place of its plain counterpart; a failing
+// command is an error carrying the command's stderr. Of several API keys
+// the first is used; callers choose another with WithKey. Auth types that
+// don't send a key leave APIKey empty, and subscription profiles resolve
+// only their extra env.
+func (p *Profile) Resolve() (*Resolved, error) {
+	lookup, err := p.Lookup()
+	if err != nil {
+		return nil, err
+	}
+	resolved := &Resolved{}
+	if resolved.Env, err = p.resolveEnv(lookup); err != nil {
+		return nil, err
+	}
+	if p.IsSubscription() {
+		return resolved, nil
+	}
+	if resolved.BaseURL, err = resolveField("base_url", p.BaseURL, p.BaseURLCmd, lookup); err != nil {
+		return nil, err
+	}
+	if p.SendsKey() {
+		key := ""
+		if len(p.APIKey) > 0 {
+			key = p.APIKey[0]
+		}
+		if resolved.APIKey, err = resolveField("api_key", key, p.APIKeyCmd, lookup); err != nil {
+			return nil, err
+		}
+	}
+	if resolved.Headers, err = p.resolveHeaders(lookup); err != nil {
+		return nil, err
+	}
+	return resolved, nil
+}
+
+// resolveHeaders expands the values of the profile's extra headers
+func (p *Profile) resolveHeaders(lookup Lookup) (map[string]string, error) {
+	if len(p.Headers) == 0 {
+		return nil, nil
+	}
+	headers := make(map[string]string, len(p.Headers))
+	for name, value := range p.Headers {
+		if err := ValidateHeaderName(name); err != nil {
+			return nil, fmt.Errorf("headers: %w", err)
+		}
+		expanded, err := ExpandWith(value, lookup)
+		if err != nil {
+			return nil, fmt.Errorf("headers.%s: %w", name, err)
+		}
+		if strings.ContainsAny(expanded, "\r\n") {
+			return nil, fmt.Errorf("headers.%s: value must not contain line breaks", name)
+		}
+		headers[name] = expanded
+	}
+	return headers, nil
+}
+
+// resolveEnv expands the values of the profile's extra env
+func (p *Profile) resolveEnv(lookup Lookup) (map[string]string, error) {
+	if len(p.Env) == 0 {
+		return nil, nil
+	}
+	env := make(map[string]string, len(p.Env))
+	for name, value := range p.Env {
+		if name == "" || !isValidName(name) {
+			return nil, fmt.Errorf("env: invalid variable name %q", name)
+		}
+		expanded, err := ExpandWith(value, lookup)
+		if err != nil {
+			return nil, fmt.Errorf("env.%s: %w", name, err)
+		}
+		env[name] = expanded
+	}
+	return env, nil
+}
+
+// ValidateHeaderName checks that name is a valid HTTP header name
+func ValidateHeaderName(name string) error {
+	if name == "" {
+		return fmt.Errorf("header name must not be empty")
+	}
+	for _, r := range name {
+		if r <= ' ' || r >= 0x7f || strings.ContainsRune("\"(),/:;<=>?@[\\]{}", r) {
+			return fmt.Errorf("invalid header name %q", name)
+		}
+	}
+	return nil
+}
+
+// resolveField returns the value of a field that may come from a command
+func resolveField(name, value, command string, lookup Lookup) (string, error) {
+	if command == "" {
+		expanded, err := ExpandWith(value, lookup)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", name, err)
+		}
+		return expanded, nil
+	}
+
+	if value != "" {
+		return "", fmt.Errorf("%s and %s_cmd are both set; keep only one", name, name)
+	}
+	out, err := RunCommand(command, CommandTimeout)
+	if err != nil {
+		return "", fmt.Errorf("%s_cmd: %w", name, err)
+	}
+	if out == "" {
+		return "", fmt.Errorf("%s_cmd: %q printed nothing", name, command)
+	}
+	return out, nil
+}
+
+// RunCommand runs command through the platform shell and returns its
+// trimmed stdout. Stdin stays attached so tools like gpg or op can prompt.
+func RunCommand(command string, timeout time.Duration) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return "", fmt.Errorf("%q timed out after %s", command, timeout)
+	}
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && msg != "" {
+			return "", fmt.Errorf("%q failed: %s", command, msg)
+		}
+		return "", fmt.Errorf("%q failed: %w", command, err)
+	}
+
+	return strings.TrimSpace(stdout.String()), nil
+}
@@ -0,0 +1,338 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/nanmi/cc-portkey/internal/fileutil"
+)
+
+// SchemaFileName is the name of the schema file written next to the config
+const SchemaFileName = "config.schema.json"
+
+// Schema returns a JSON Schema (draft-07) describing config.json,
+// generated from the Config and Profile structs
+func Schema() map[string]interface{} {
+	schema := schemaFor(reflect.TypeOf(Config{}))
+	schema["$schema"] = "http://json-schema.org/draft-07/schema#"
+	schema["title"] = "cc-portkey configuration"
+
+	// Model slots are map keys, so they can't come from struct fields
+	profile := schema["properties"].(map[string]interface{})["profiles"].(map[string]interface{})["additionalProperties"].(map[string]interface{})
+	models := profile["properties"].(map[string]interface{})["models"].(map[string]interface{})
+	slots := make(map[string]interface{}, len(ModelSlots)+len(ModelSlotAliases))
+	for _, slot := range ModelSlots {
+		slots[slot.Key] = map[string]interface{}{
+			"type":        "string",
+			"description": fmt.Sprintf("%s (%s)", slot.Doc, slot.EnvVar),
+		}
+	}
+	for key, target := range ModelSlotAliases {
+		slots[key] = map[string]interface{}{
+			"type":        "string",
+			"description": fmt.Sprintf("Deprecated: use %s", target),
+		}
+	}
+	// Other keys stay valid: Load accepts them and doctor warns that they're ignored
+	models["properties"] = slots
+
+	return schema
+}
+
+// SchemaRef returns the $schema value init writes into a config file:
+// a path to the schema file relative to the config file
+func SchemaRef() string {
+	return "./" + SchemaFileName
+}
+
+// SchemaPath returns where the schema file for the current config lives
+func SchemaPath() (string, error) {
+	path, err := ConfigPath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(path), SchemaFileName), nil
+}
+
+// schemaProvider is implemented by types whose JSON form isn't implied
+// by their Go type
+type schemaProvider interface {
+	jsonSchema() map[string]interface{}
+}
+
+// schemaFor builds the schema for a Go type
+func schemaFor(t reflect.Type) map[string]interface{} {
+	if p, ok := reflect.Zero(t).Interface().(schemaProvider); ok {
+		return p.jsonSchema()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		props := make(map[string]interface{})
+		for _, f := range jsonFields(t) {
+			s := schemaFor(f.typ)
+			if f.doc != "" {
+				s["description"] = f.doc
+			}
+			if f.enum != "" {
+				s["enum"] = strings.Split(f.enum, ",")
+			}
+			props[f.name] = s
+		}
+		return map[string]interface{}{
+			"type":                 "object",
+			"properties":           props,
+			"additionalProperties": false,
+		}
+	case reflect.Map:
+		return map[string]interface{}{
+			"type":                 "object",
+			"additionalProperties": schemaFor(t.Elem()),
+		}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{
+			"type":  "array",
+			"items": schemaFor(t.Elem()),
+		}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Ptr:
+		return schemaFor(t.Elem())
+	}
+	return map[string]interface{}{}
+}
+
+// jsonField is a struct field as it appears in JSON
+type jsonField struct {
+	name      string
+	doc       string
+	enum      string // comma-separated allowed values
+	omitEmpty bool
+	index     []int
+	typ       reflect.Type
+}
+
+// jsonFields lists the JSON-visible fields of a struct type
+func jsonFields(t reflect.Type) []jsonField {
+	var fields []jsonField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name := jsonName(f)
+		if name == "-" {
+			continue
+		}
+		fields = append(fields, jsonField{
+			name:      name,
+			doc:       f.Tag.Get("doc"),
+			enum:      f.Tag.Get("enum"),
+			omitEmpty: strings.Contains(f.Tag.Get("json"), ",omitempty"),
+			index:     f.Index,
+			typ:       f.Type,
+		})
+	}
+	return fields
+}
+
+// jsonName returns the JSON name of a struct field, "-" for skipped fields
+func jsonName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	if name == "" {
+		return f.Name
+	}
+	return name
+}
+
+// checkUnknownFields reports object keys in data that don't correspond to a
+// field of the expected struct, with their line and column. encoding/json
+// silently drops such keys, which hides typos like "timeout_MS".
+func checkUnknownFields(data []byte, t reflect.Type) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	var problems []string
+	if err := walkFields(dec, data, t, "", &problems); err != nil {
+		return nil // syntax errors are reported by json.Unmarshal
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%s", strings.Join(problems, "\n"))
+}
+
+// walkFields consumes one JSON value from dec, checking object keys against t
+func walkFields(dec *json.Decoder, data []byte, t reflect.Type, path string, problems *[]string) error {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	delim, ok := tok.(json.Delim)
+	if !ok {
+		return nil
+	}
+
+	switch delim {
+	case '{':
+		var fields map[string]jsonField
+		if t != nil && t.Kind() == reflect.Struct {
+			fields = make(map[string]jsonField)
+			for _, f := range jsonFields(t) {
+				fields[f.name] = f
+			}
+		}
+
+		for dec.More() {
+			offset := keyOffset(data, int(dec.InputOffset()))
+			tok, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			key := tok.(string)
+			childPath := key
+			if path != "" {
+				childPath = path + "." + key
+			}
+
+			var child reflect.Type
+			switch {
+			case fields != nil:
+				f, ok := fields[key]
+				if !ok {
+					line, col := lineColumn(data, offset)
+					msg := fmt.Sprintf("  line %d, column %d: unknown field %q", line, col, key)
+					if path != "" {
+						msg += " in " + path
+					}
+					if s := suggestField(key, fields); s != "" {
+						msg += fmt.Sprintf(" (did you mean %q?)", s)
+					}
+					*problems = append(*problems, msg)
+				} else {
+					child = f.typ
+				}
+			case t != nil && t.Kind() == reflect.Map:
+				child = t.Elem()
+			}
+
+			if err := walkFields(dec, data, child, childPath, problems); err != nil {
+				return err
+			}
+		}
+	case '[':
+		var elem reflect.Type
+		if t != nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+			elem = t.Elem()
+		}
+		for i := 0; dec.More(); i++ {
+			if err := walkFields(dec, data, elem, fmt.Sprintf("%s[%d]", path, i), problems); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Closing delimiter
+	_, err = dec.Token()
+	return err
+}
+
+// keyOffset advances from the decoder's offset past separators to the opening
+// quote of the next object key
+func keyOffset(data []byte, offset int) int {
+	for offset < len(data) && strings.IndexByte(" \t\r\n,{", data[offset]) >= 0 {
+		offset++
+	}
+	return offset
+}
+
+// lineColumn converts a byte offset into a 1-based line and column
+func lineColumn(data []byte, offset int) (int, int) {
+	line := 1 + bytes.Count(data[:offset], []byte("\n"))
+	col := offset - bytes.LastIndexByte(data[:offset], '\n')
+	return line, col
+}
+
+// suggestField returns the known field closest to key, if any is close enough
+func suggestField(key string, fields map[string]jsonField) string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	best, bestDist := "", 3
+	for _, name := range names {
+		if strings.EqualFold(name, key) {
+			return name
+		}
+		if d := editDistance(key, name); d < bestDist {
+			best, bestDist = name, d
+		}
+	}
+	return best
+}
+
+// editDistance is the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// SchemaJSON returns the schema as indented JSON
+func SchemaJSON() ([]byte, error) {
+	data, err := json.MarshalIndent(Schema(), "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal schema: %w", err)
+	}
+	return append(data, '\n'), nil
+}
+
+// WriteSchema writes the schema file next to the config file and returns its path
+func WriteSchema() (string, error) {
+	path, err := SchemaPath()
+	if err != nil {
+		return "", err
+	}
+	data, err := SchemaJSON()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("failed to create config directory: %w", err)
+	}
+	if err := fileutil.WriteFileAtomic(path, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to write schema: %w", err)
+	}
+	return path, nil
+}
@@ -0,0 +1,61 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SettingsOverlay returns the profile's settings as dotted paths to the
+// values they set, e.g. "statusLine.command". Objects are merged into
+// settings.json key by key; any other value, arrays included, replaces what
+// is there, and null removes it. Values are written as is, without ${VAR}
+// expansion, so hook commands keep their shell syntax.
+func (p *Profile) SettingsOverlay() (map[string]interface{}, error) {
+	if _, ok := p.Settings["env"]; ok {
+		return nil, fmt.Errorf("settings.env: use the profile's env field instead")
+	}
+	paths := make(map[string]interface{})
+	if err := flattenSettings(p.Settings, "", paths); err != nil {
+		return nil, err
+	}
+	return paths, nil
+}
+
+// flattenSettings adds the leaves of obj to paths, prefixing their keys
+func flattenSettings(obj map[string]interface{}, prefix string, paths map[string]interface{}) error {
+	for key, value := range obj {
+		if key == "" || strings.Contains(key, ".") {
+			return fmt.Errorf("settings: key %q in %q can't be overlaid; keys must be non-empty and free of dots", key, strings.TrimSuffix(prefix, "."))
+		}
+		if child, ok := value.(map[string]interface{}); ok && len(child) > 0 {
+			if err := flattenSettings(child, prefix+key+".", paths); err != nil {
+				return err
+			}
+			continue
+		}
+		paths[prefix+key] = value
+	}
+	return nil
+}
+
+// mergeSettings deep-merges src into dst, recording source as the origin of
+// each leaf of src under prefix. Nested objects of dst are copied before
+// they change, so objects shared with other profiles stay untouched.
+func mergeSettings(dst, src map[string]interface{}, prefix, source string, sources map[string]string) {
+	for key, value := range src {
+		child, isObject := value.(map[string]interface{})
+		if !isObject || len(child) == 0 {
+			dst[key] = value
+			sources[prefix+key] = source
+			continue
+		}
+		merged := make(map[string]interface{})
+		if existing, ok := dst[key].(map[string]interface{}); ok {
+			for k, v := range existing {
+				merged[k] = v
+			}
+		}
+		dst[key] = merged
+		mergeSettings(merged, child, prefix+key+".", source, sources)
+	}
+}
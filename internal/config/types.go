@@ -1,54 +1,141 @@
 package config
 
+import (
+	"fmt"
+	"strings"
+)
+
 // Profile represents a single provider configuration
+// The doc tags are used as descriptions in the generated JSON Schema
 type Profile struct {
-	DisplayName string            `json:"display_name"`
-	BaseURL     string            `json:"base_url"`
-	APIKey      string            `json:"api_key"`
-	TimeoutMS   int               `json:"timeout_ms,omitempty"`
-	Models      map[string]string `json:"models,omitempty"`
+	DisplayName string                 `json:"display_name" doc:"Human-readable name shown in output"`
+	Extends     string                 `json:"extends,omitempty" doc:"Name of a profile to inherit unset fields from; models, headers and env are merged key by key, settings all the way down"`
+	Kind        string                 `json:"kind,omitempty" doc:"api (the default) uses base_url and api_key; subscription clears them so Claude Code uses its own Pro/Max login" enum:"api,subscription"`
+	BaseURL     string                 `json:"base_url,omitempty" doc:"API endpoint URL; empty for the official Claude API. Supports ${VAR} references"`
+	APIKey      StringList             `json:"api_key,omitempty" doc:"API key, ${VAR} or ${secret:NAME} reference, or a list of keys to rotate through"`
+	KeyStrategy string                 `json:"key_strategy,omitempty" doc:"How to pick among several api_key entries" enum:"round_robin,random,sticky_per_project"`
+	AuthType    string                 `json:"auth_type,omitempty" doc:"How the API key is sent: bearer (ANTHROPIC_AUTH_TOKEN, the default), x-api-key (ANTHROPIC_API_KEY), none, or custom_headers (only headers are sent)" enum:"bearer,x-api-key,none,custom_headers"`
+	Headers     map[string]string      `json:"headers,omitempty" doc:"Extra HTTP headers sent with every request (ANTHROPIC_CUSTOM_HEADERS). Values support ${VAR} references"`
+	BaseURLCmd  string                 `json:"base_url_cmd,omitempty" doc:"Shell command printing the base URL; replaces base_url"`
+	APIKeyCmd   string                 `json:"api_key_cmd,omitempty" doc:"Shell command printing the API key, e.g. \"pass show deepseek\"; replaces api_key"`
+	EnvFile     StringList             `json:"env_file,omitempty" doc:"Dotenv file(s) supplying variables for this profile's references; the process environment takes precedence"`
+	Env         map[string]string      `json:"env,omitempty" doc:"Extra environment variables for Claude Code, e.g. CLAUDE_CODE_MAX_OUTPUT_TOKENS or HTTPS_PROXY. Values support ${VAR} references"`
+	Settings    map[string]interface{} `json:"settings,omitempty" doc:"Claude Code settings deep-merged into settings.json, e.g. statusLine or permissions; null removes a setting"`
+	TimeoutMS   int                    `json:"timeout_ms,omitempty" doc:"Request timeout in milliseconds (API_TIMEOUT_MS)"`
+	Models      map[string]string      `json:"models,omitempty" doc:"Model names by slot: default, opus, sonnet, haiku or subagent"`
+
+	// present records the fields the config file sets, so a profile can
+	// override an inherited value with an empty one; see overlay
+	present map[string]bool
 }
 
 // Config represents the main configuration file structure
 type Config struct {
-	Current  string             `json:"current"`
-	Profiles map[string]Profile `json:"profiles"`
+	Schema    string             `json:"$schema,omitempty" doc:"JSON Schema reference for editor completion and validation"`
+	Version   int                `json:"version" doc:"Config schema version, maintained by cc-portkey"`
+	Current   string             `json:"current" doc:"Profile currently applied to ~/.claude/settings.json"`
+	KeyHelper bool               `json:"key_helper,omitempty" doc:"Point Claude Code's apiKeyHelper at cc-portkey instead of writing API keys into settings files"`
+	Profiles  map[string]Profile `json:"profiles" doc:"Provider profiles by name"`
+	Aliases   map[string]Alias   `json:"aliases,omitempty" doc:"Shortcut commands by name; 'cc-portkey link' creates a symlink for each"`
 }
 
-// AliasMapping maps short aliases to profile names
-var AliasMapping = map[string]string{
-	"ccc": "claude", // ccc = Claude Code CLI (避免与 C 编译器 cc 冲突)
-	"ds":  "deepseek",
-	"glm": "glm",
-	"mm":  "minimax",
+// Alias is a shortcut command that launches Claude Code with a profile
+type Alias struct {
+	Profile string            `json:"profile" doc:"Profile the shortcut launches"`
+	Args    []string          `json:"args,omitempty" doc:"Arguments passed to claude before the ones given on the command line"`
+	Env     map[string]string `json:"env,omitempty" doc:"Extra environment variables for the session, merged over the profile's env"`
+}
+
+// Key rotation strategies for profiles with several API keys
+const (
+	KeyRoundRobin       = "round_robin"
+	KeyRandom           = "random"
+	KeyStickyPerProject = "sticky_per_project"
+)
+
+// Profile kinds
+const (
+	KindAPI          = "api"
+	KindSubscription = "subscription"
+)
+
+// SubscriptionLabel describes subscription profiles in place of a key
+const SubscriptionLabel = "Claude subscription login"
+
+// IsSubscription reports whether the profile uses Claude Code's own login
+func (p *Profile) IsSubscription() bool {
+	return p.Kind == KindSubscription
+}
+
+// Auth types deciding how a profile's API key reaches the provider
+const (
+	AuthBearer        = "bearer"
+	AuthXAPIKey       = "x-api-key"
+	AuthNone          = "none"
+	AuthCustomHeaders = "custom_headers"
+)
+
+// SendsKey reports whether the profile sends its API key
+func (p *Profile) SendsKey() bool {
+	return !p.IsSubscription() && p.AuthType != AuthNone && p.AuthType != AuthCustomHeaders
+}
+
+// KeyCount returns how many API keys the profile has
+func (p *Profile) KeyCount() int {
+	return len(p.APIKey)
+}
+
+// WithKey returns a copy of the profile using only its i-th API key
+func (p *Profile) WithKey(i int) Profile {
+	narrowed := *p
+	if i >= 0 && i < len(p.APIKey) {
+		narrowed.APIKey = StringList{p.APIKey[i]}
+	}
+	return narrowed
+}
+
+// ValidateAliasName checks that an alias can be used as a command name
+func ValidateAliasName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\:*?"<>| `) {
+		return fmt.Errorf("invalid alias name %q", name)
+	}
+	if strings.EqualFold(name, "cc-portkey") {
+		return fmt.Errorf("alias name %q would shadow cc-portkey itself", name)
+	}
+	// Aliases launch claude, so a link named claude would launch itself
+	if strings.EqualFold(name, "claude") {
+		return fmt.Errorf("alias name %q would shadow Claude Code, which aliases launch", name)
+	}
+	return nil
 }
 
 // DefaultConfig returns a default configuration with common providers
 func DefaultConfig() *Config {
 	return &Config{
+		Schema:  SchemaRef(),
+		Version: CurrentVersion,
 		Current: "claude",
 		Profiles: map[string]Profile{
 			"claude": {
 				DisplayName: "Claude",
-				BaseURL:     "${ANTHROPIC_BASE_URL}", // 支持中转，留空则使用官方地址
-				APIKey:      "${ANTHROPIC_API_KEY}",
+				Kind:        KindSubscription, // 使用 Claude Code 自身的 Pro/Max 登录
 				TimeoutMS:   120000,
 				Models:      map[string]string{},
 			},
 			"deepseek": {
 				DisplayName: "DeepSeek",
 				BaseURL:     "https://api.deepseek.com/anthropic",
-				APIKey:      "${DEEPSEEK_API_KEY}",
+				APIKey:      StringList{"${DEEPSEEK_API_KEY}"},
 				TimeoutMS:   600000,
 				Models: map[string]string{
-					"default":    "deepseek-chat",
-					"small_fast": "deepseek-chat",
+					"default": "deepseek-chat",
+					"haiku":   "deepseek-chat",
 				},
 			},
 			"glm": {
 				DisplayName: "GLM (Zhipu)",
 				BaseURL:     "https://open.bigmodel.cn/api/anthropic",
-				APIKey:      "${GLM_API_KEY}",
+				APIKey:      StringList{"${GLM_API_KEY}"},
 				TimeoutMS:   3000000,
 				Models: map[string]string{
 					"opus":   "glm-4.6",
@@ -59,16 +146,21 @@ func DefaultConfig() *Config {
 			"minimax": {
 				DisplayName: "MiniMax",
 				BaseURL:     "https://api.minimaxi.com/anthropic",
-				APIKey:      "${MINIMAX_API_KEY}",
+				APIKey:      StringList{"${MINIMAX_API_KEY}"},
 				TimeoutMS:   3000000,
 				Models: map[string]string{
-					"default":    "MiniMax-M2",
-					"small_fast": "MiniMax-M2",
-					"opus":       "MiniMax-M2",
-					"sonnet":     "MiniMax-M2",
-					"haiku":      "MiniMax-M2",
+					"default": "MiniMax-M2",
+					"opus":    "MiniMax-M2",
+					"sonnet":  "MiniMax-M2",
+					"haiku":   "MiniMax-M2",
 				},
 			},
 		},
+		Aliases: map[string]Alias{
+			"ccc": {Profile: "claude"}, // ccc = Claude Code CLI (避免与 C 编译器 cc 冲突)
+			"ds":  {Profile: "deepseek"},
+			"glm": {Profile: "glm"},
+			"mm":  {Profile: "minimax"},
+		},
 	}
 }
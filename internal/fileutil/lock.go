@@ -0,0 +1,73 @@
+// Package fileutil provides cross-process file locking and atomic writes
+// for the files cc-portkey shares between concurrent invocations.
+package fileutil
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// DefaultLockTimeout is how long Acquire waits for another process
+	DefaultLockTimeout = 10 * time.Second
+
+	lockRetryInterval = 50 * time.Millisecond
+)
+
+// Lock is an advisory lock held on a lock file
+type Lock struct {
+	file *os.File
+}
+
+// Acquire takes an exclusive advisory lock on path, creating the file if
+// needed. It waits up to timeout for other processes to release the lock.
+// The lock is released by Release or when the process exits.
+func Acquire(path string, timeout time.Duration) (*Lock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create lock directory: %w", err)
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		ok, err := tryLock(f)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+		}
+		if ok {
+			return &Lock{file: f}, nil
+		}
+		if time.Now().After(deadline) {
+			f.Close()
+			return nil, fmt.Errorf("timed out after %s waiting for lock %s: another cc-portkey process is still writing", timeout, path)
+		}
+		time.Sleep(lockRetryInterval)
+	}
+}
+
+// Release unlocks and closes the lock file
+func (l *Lock) Release() error {
+	if err := unlock(l.file); err != nil {
+		l.file.Close()
+		return err
+	}
+	return l.file.Close()
+}
+
+// WithLock runs fn while holding the lock at path
+func WithLock(path string, fn func() error) error {
+	lock, err := Acquire(path, DefaultLockTimeout)
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
+	return fn()
+}
@@ -0,0 +1,15 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd || windows)
+
+package fileutil
+
+import "os"
+
+// tryLock always succeeds on platforms without flock or LockFileEx
+func tryLock(f *os.File) (bool, error) {
+	return true, nil
+}
+
+// unlock is a no-op on platforms without flock or LockFileEx
+func unlock(f *os.File) error {
+	return nil
+}
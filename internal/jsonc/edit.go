@@ -0,0 +1,431 @@
+package jsonc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// defaultIndent is used when the document gives no hint about its indentation
+const defaultIndent = "  "
+
+// Document is a parsed JSONC document that can be edited in place.
+// Edits splice new text into the original bytes, so comments, key order,
+// indentation and everything outside the edited values stay byte-for-byte.
+type Document struct {
+	data []byte
+	root *node
+}
+
+// Parse parses a JSONC document. Empty input is treated as an empty object.
+func Parse(data []byte) (*Document, error) {
+	d := &Document{data: append([]byte(nil), data...)}
+	if err := d.reparse(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Bytes returns the current text of the document
+func (d *Document) Bytes() []byte {
+	return d.data
+}
+
+// Value decodes the document into a generic map, the same shape
+// json.Unmarshal produces for an object
+func (d *Document) Value() (map[string]interface{}, error) {
+	if d.root == nil {
+		return map[string]interface{}{}, nil
+	}
+	if d.root.kind != kindObject {
+		return nil, fmt.Errorf("top-level value is not an object")
+	}
+	v, err := decode(d.data, d.root)
+	if err != nil {
+		return nil, err
+	}
+	return v.(map[string]interface{}), nil
+}
+
+// Get returns the value at path, decoded into its generic Go representation
+func (d *Document) Get(path ...string) (interface{}, bool) {
+	n := d.lookup(path)
+	if n == nil {
+		return nil, false
+	}
+	v, err := decode(d.data, n)
+	if err != nil {
+		return nil, false
+	}
+	return v, true
+}
+
+// Set writes value at path, creating intermediate objects as needed.
+// An existing value is replaced in place; a new key is appended to its object.
+func (d *Document) Set(path []string, value interface{}) error {
+	if len(path) == 0 {
+		return fmt.Errorf("empty path")
+	}
+	if err := d.ensureRoot(); err != nil {
+		return err
+	}
+
+	obj := d.root
+	for i, key := range path {
+		idx := findMember(obj, key)
+		rest := path[i+1:]
+
+		if idx < 0 {
+			return d.insertMember(obj, key, nest(rest, value))
+		}
+
+		m := obj.members[idx]
+		if len(rest) == 0 || m.value.kind != kindObject {
+			return d.replaceValue(m, nest(rest, value))
+		}
+		obj = m.value
+	}
+	return nil
+}
+
+// Delete removes the member at path, and any duplicates of it, so the key
+// is gone rather than exposing an earlier value. It reports whether anything
+// was removed.
+func (d *Document) Delete(path []string) (bool, error) {
+	if len(path) == 0 {
+		return false, fmt.Errorf("empty path")
+	}
+	removed := false
+	for {
+		// Each deletion reparses the document, so look the parent up again
+		parent := d.lookup(path[:len(path)-1])
+		if parent == nil || parent.kind != kindObject {
+			return removed, nil
+		}
+		idx := findMember(parent, path[len(path)-1])
+		if idx < 0 {
+			return removed, nil
+		}
+		if err := d.deleteMember(parent, idx); err != nil {
+			return removed, err
+		}
+		removed = true
+	}
+}
+
+// lookup returns the node at path, or nil if it doesn't exist
+func (d *Document) lookup(path []string) *node {
+	n := d.root
+	for _, key := range path {
+		if n == nil || n.kind != kindObject {
+			return nil
+		}
+		idx := findMember(n, key)
+		if idx < 0 {
+			return nil
+		}
+		n = n.members[idx].value
+	}
+	return n
+}
+
+// findMember returns the index of the last member named key, or -1.
+// The last one wins for duplicate keys, matching encoding/json.
+func findMember(obj *node, key string) int {
+	for i := len(obj.members) - 1; i >= 0; i-- {
+		if obj.members[i].key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// nest wraps value in objects for each remaining path element
+func nest(path []string, value interface{}) interface{} {
+	for i := len(path) - 1; i >= 0; i-- {
+		value = map[string]interface{}{path[i]: value}
+	}
+	return value
+}
+
+// ensureRoot makes sure the document has a top-level object
+func (d *Document) ensureRoot() error {
+	if d.root != nil {
+		if d.root.kind != kindObject {
+			return fmt.Errorf("top-level value is not an object")
+		}
+		return nil
+	}
+	// Empty or comment-only document
+	text := strings.TrimRight(string(d.data), " \t\r\n")
+	if text != "" {
+		text += "\n"
+	}
+	d.data = []byte(text + "{}\n")
+	return d.reparse()
+}
+
+// reparse rebuilds the node tree after the text has changed
+func (d *Document) reparse() error {
+	root, err := parse(d.data)
+	if err != nil {
+		return err
+	}
+	d.root = root
+	return nil
+}
+
+// splice is a single text replacement
+type splice struct {
+	start, end int
+	text       string
+}
+
+// apply performs the splices (which must not overlap) and reparses
+func (d *Document) apply(splices ...splice) error {
+	sort.Slice(splices, func(i, j int) bool { return splices[i].start > splices[j].start })
+	for _, s := range splices {
+		var buf bytes.Buffer
+		buf.Write(d.data[:s.start])
+		buf.WriteString(s.text)
+		buf.Write(d.data[s.end:])
+		d.data = buf.Bytes()
+	}
+	return d.reparse()
+}
+
+// replaceValue replaces the value of an existing member
+func (d *Document) replaceValue(m member, value interface{}) error {
+	text, err := d.format(value, d.lineIndent(m.keyStart))
+	if err != nil {
+		return err
+	}
+	return d.apply(splice{m.value.start, m.value.end, text})
+}
+
+// insertMember appends a new member to obj, following the layout of its
+// existing members (one per line or inline, trailing comma or not)
+func (d *Document) insertMember(obj *node, key string, value interface{}) error {
+	keyText, err := d.format(key, "")
+	if err != nil {
+		return err
+	}
+
+	if len(obj.members) == 0 {
+		outer := d.lineIndent(obj.start)
+		indent := outer + d.indentUnit()
+		text, err := d.format(value, indent)
+		if err != nil {
+			return err
+		}
+		entry := keyText + ": " + text
+
+		inner := obj.start + 1
+		if isBlank(d.data[inner : obj.end-1]) {
+			return d.apply(splice{inner, obj.end - 1, d.newline() + indent + entry + d.newline() + outer})
+		}
+		// Keep comments inside an otherwise empty object
+		return d.apply(splice{inner, inner, d.newline() + indent + entry})
+	}
+
+	first := obj.members[0]
+	last := obj.members[len(obj.members)-1]
+	multiline := d.startsLine(first.keyStart)
+
+	if !multiline {
+		text, err := encode(value, "", "")
+		if err != nil {
+			return err
+		}
+		entry := keyText + ": " + text
+		if last.comma >= 0 {
+			return d.apply(splice{last.comma + 1, last.comma + 1, " " + entry + ","})
+		}
+		return d.apply(splice{last.value.end, last.value.end, ", " + entry})
+	}
+
+	indent := d.lineIndent(first.keyStart)
+	text, err := d.format(value, indent)
+	if err != nil {
+		return err
+	}
+	entry := keyText + ": " + text
+
+	if last.comma >= 0 {
+		at := d.insertionPoint(last.comma + 1)
+		return d.apply(splice{at, at, d.newline() + indent + entry + ","})
+	}
+	at := d.insertionPoint(last.value.end)
+	between := string(d.data[last.value.end:at])
+	return d.apply(splice{last.value.end, at, "," + between + d.newline() + indent + entry})
+}
+
+// deleteMember removes obj.members[idx] along with its line when it has one
+// to itself, fixing up the separating comma. Comments after the member on
+// its line, line or block, belong to it and are removed with it.
+func (d *Document) deleteMember(obj *node, idx int) error {
+	m := obj.members[idx]
+
+	start, end := m.keyStart, m.value.end
+	if m.comma >= 0 {
+		end = m.comma + 1
+	}
+
+	ownLine := d.startsLine(m.keyStart)
+	if !ownLine && m.comma >= 0 {
+		// Inline, the next member takes this one's place, so drop the space
+		// that separated them
+		for end < len(d.data) && (d.data[end] == ' ' || d.data[end] == '\t') {
+			end++
+		}
+	}
+	if ownLine {
+		start = lineStart(d.data, m.keyStart)
+		// Take the rest of the line too if only whitespace or comments follow
+		if at, ok := d.lineEnd(end); ok {
+			end = at
+			if end < len(d.data) && d.data[end] == '\r' {
+				end++
+			}
+			if end < len(d.data) && d.data[end] == '\n' {
+				end++
+			}
+		}
+	}
+
+	splices := []splice{{start, end, ""}}
+	if m.comma < 0 && idx > 0 {
+		prev := obj.members[idx-1]
+		if ownLine {
+			splices = append(splices, splice{prev.comma, prev.comma + 1, ""})
+		} else {
+			splices = []splice{{prev.comma, end, ""}}
+		}
+	}
+
+	if len(obj.members) == 1 {
+		// Collapse to {} when nothing but whitespace would be left
+		rest := string(d.data[obj.start+1:start]) + string(d.data[end:obj.end-1])
+		if isBlank([]byte(rest)) {
+			splices = []splice{{obj.start + 1, obj.end - 1, ""}}
+		}
+	}
+
+	return d.apply(splices...)
+}
+
+// format encodes value as JSON in the document's style, indenting nested
+// lines with prefix
+func (d *Document) format(value interface{}, prefix string) (string, error) {
+	text, err := encode(value, prefix, d.indentUnit())
+	if err != nil {
+		return "", err
+	}
+	return strings.ReplaceAll(text, "\n", d.newline()), nil
+}
+
+// encode marshals value without HTML escaping; empty prefix and indent
+// produce compact output
+func encode(value interface{}, prefix, indent string) (string, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent(prefix, indent)
+	if err := enc.Encode(value); err != nil {
+		return "", fmt.Errorf("failed to encode value: %w", err)
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+// newline returns the document's line ending
+func (d *Document) newline() string {
+	if bytes.Contains(d.data, []byte("\r\n")) {
+		return "\r\n"
+	}
+	return "\n"
+}
+
+// indentUnit guesses the document's indentation from its first indented member
+func (d *Document) indentUnit() string {
+	if d.root != nil && d.root.kind == kindObject && len(d.root.members) > 0 {
+		m := d.root.members[0]
+		if d.startsLine(m.keyStart) {
+			if indent := d.lineIndent(m.keyStart); indent != "" {
+				return indent
+			}
+		}
+	}
+	return defaultIndent
+}
+
+// lineIndent returns the leading whitespace of the line containing pos
+func (d *Document) lineIndent(pos int) string {
+	start := lineStart(d.data, pos)
+	end := start
+	for end < len(d.data) && (d.data[end] == ' ' || d.data[end] == '\t') {
+		end++
+	}
+	return string(d.data[start:end])
+}
+
+// startsLine reports whether only whitespace precedes pos on its line
+func (d *Document) startsLine(pos int) bool {
+	return isBlank(d.data[lineStart(d.data, pos):pos])
+}
+
+// insertionPoint returns where a new line can be inserted after pos without
+// separating a trailing line comment from the text it belongs to
+func (d *Document) insertionPoint(pos int) int {
+	if at, ok := d.lineEnd(pos); ok {
+		return at
+	}
+	return pos
+}
+
+// lineEnd returns the offset of the newline (or end of input) ending the line
+// at pos, and whether the rest of that line is only whitespace and comments.
+// A block comment that starts on the line counts as part of it even if it
+// spans several lines.
+func (d *Document) lineEnd(pos int) (int, bool) {
+	i := pos
+	for {
+		for i < len(d.data) && (d.data[i] == ' ' || d.data[i] == '\t' || d.data[i] == '\r') {
+			i++
+		}
+		if i+1 >= len(d.data) || d.data[i] != '/' || d.data[i+1] != '*' {
+			break
+		}
+		end := bytes.Index(d.data[i+2:], []byte("*/"))
+		if end < 0 {
+			return pos, false
+		}
+		i += end + 4
+	}
+	if i+1 < len(d.data) && d.data[i] == '/' && d.data[i+1] == '/' {
+		for i < len(d.data) && d.data[i] != '\n' {
+			i++
+		}
+	}
+	if i == len(d.data) {
+		return i, true
+	}
+	if d.data[i] == '\n' {
+		if i > pos && d.data[i-1] == '\r' {
+			i--
+		}
+		return i, true
+	}
+	return pos, false
+}
+
+// lineStart returns the offset of the first byte of the line containing pos
+func lineStart(data []byte, pos int) int {
+	return bytes.LastIndexByte(data[:pos], '\n') + 1
+}
+
+// isBlank reports whether b contains only whitespace
+func isBlank(b []byte) bool {
+	return len(bytes.TrimSpace(b)) == 0
+}
@@ -0,0 +1,404 @@
+package jsonc
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// edit is one Set or Delete applied to a document
+type edit struct {
+	path   []string
+	value  interface{}
+	delete bool
+}
+
+func set(value interface{}, path ...string) edit { return edit{path: path, value: value} }
+func del(path ...string) edit                    { return edit{path: path, delete: true} }
+
+func TestEdit(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		edits []edit
+		want  string
+	}{
+		{
+			name:  "empty file",
+			input: "",
+			edits: []edit{set("x", "env", "A")},
+			want:  "{\n  \"env\": {\n    \"A\": \"x\"\n  }\n}\n",
+		},
+		{
+			name:  "comment-only file",
+			input: "// Claude Code settings\n",
+			edits: []edit{set("x", "env", "A")},
+			want:  "// Claude Code settings\n{\n  \"env\": {\n    \"A\": \"x\"\n  }\n}\n",
+		},
+		{
+			name:  "empty object",
+			input: "{}\n",
+			edits: []edit{set("x", "env", "A")},
+			want:  "{\n  \"env\": {\n    \"A\": \"x\"\n  }\n}\n",
+		},
+		{
+			name: "line and block comments are kept",
+			input: `{
+  // model to use
+  "model": "opus", /* pinned */
+  "env": {
+    "A": "1" // first
+  }
+}
+`,
+			edits: []edit{set("2", "env", "B")},
+			want: `{
+  // model to use
+  "model": "opus", /* pinned */
+  "env": {
+    "A": "1", // first
+    "B": "2"
+  }
+}
+`,
+		},
+		{
+			name: "replace keeps surrounding comments",
+			input: `{
+  "env": {
+    /* before */ "A": "1" /* after */
+  }
+}
+`,
+			edits: []edit{set("2", "env", "A")},
+			want: `{
+  "env": {
+    /* before */ "A": "2" /* after */
+  }
+}
+`,
+		},
+		{
+			name: "trailing comma",
+			input: `{
+  "env": {
+    "A": "1",
+  },
+}
+`,
+			edits: []edit{set("2", "env", "B")},
+			want: `{
+  "env": {
+    "A": "1",
+    "B": "2",
+  },
+}
+`,
+		},
+		{
+			name:  "CRLF endings",
+			input: "{\r\n  \"model\": \"opus\"\r\n}\r\n",
+			edits: []edit{set("x", "env", "A")},
+			want:  "{\r\n  \"model\": \"opus\",\r\n  \"env\": {\r\n    \"A\": \"x\"\r\n  }\r\n}\r\n",
+		},
+		{
+			name:  "CRLF delete",
+			input: "{\r\n  \"model\": \"opus\",\r\n  \"theme\": \"dark\"\r\n}\r\n",
+			edits: []edit{del("theme")},
+			want:  "{\r\n  \"model\": \"opus\"\r\n}\r\n",
+		},
+		{
+			name:  "tab indentation",
+			input: "{\n\t\"model\": \"opus\"\n}\n",
+			edits: []edit{set("x", "env", "A")},
+			want:  "{\n\t\"model\": \"opus\",\n\t\"env\": {\n\t\t\"A\": \"x\"\n\t}\n}\n",
+		},
+		{
+			name:  "inline object insert",
+			input: `{"a":1,"b":2}`,
+			edits: []edit{set(3, "c")},
+			want:  `{"a":1,"b":2, "c": 3}`,
+		},
+		{
+			name:  "inline object delete last",
+			input: `{"a":1,"b":2}`,
+			edits: []edit{del("b")},
+			want:  `{"a":1}`,
+		},
+		{
+			name:  "inline object delete first",
+			input: `{"a":1,"b":2}`,
+			edits: []edit{del("a")},
+			want:  `{"b":2}`,
+		},
+		{
+			name:  "spaced inline object delete first",
+			input: `{"a": 1, "b": 2}`,
+			edits: []edit{del("a")},
+			want:  `{"b": 2}`,
+		},
+		{
+			name:  "spaced inline object delete middle",
+			input: `{"a": 1, "b": 2, "c": 3}`,
+			edits: []edit{del("b")},
+			want:  `{"a": 1, "c": 3}`,
+		},
+		{
+			name:  "string containing ,}",
+			input: `{"a":"x,}","b":2}`,
+			edits: []edit{del("b"), set("y", "c")},
+			want:  `{"a":"x,}", "c": "y"}`,
+		},
+		{
+			name: "string containing ,} on its own line",
+			input: `{
+  "statusLine": "echo ,}",
+  "model": "opus"
+}
+`,
+			edits: []edit{del("model")},
+			want: `{
+  "statusLine": "echo ,}"
+}
+`,
+		},
+		{
+			name:  "duplicate keys edit the last",
+			input: "{\n  \"model\": \"a\",\n  \"model\": \"b\"\n}\n",
+			edits: []edit{set("c", "model")},
+			want:  "{\n  \"model\": \"a\",\n  \"model\": \"c\"\n}\n",
+		},
+		{
+			name:  "duplicate keys delete every occurrence",
+			input: "{\n  \"model\": \"a\",\n  \"theme\": \"dark\",\n  \"model\": \"b\"\n}\n",
+			edits: []edit{del("model")},
+			want:  "{\n  \"theme\": \"dark\"\n}\n",
+		},
+		{
+			name:  "duplicate inline keys delete every occurrence",
+			input: `{"model": "a", "theme": "dark", "model": "b"}`,
+			edits: []edit{del("model")},
+			want:  `{"theme": "dark"}`,
+		},
+		{
+			name:  "delete the only member",
+			input: "{\n  \"env\": {\n    \"A\": \"1\"\n  }\n}\n",
+			edits: []edit{del("env", "A")},
+			want:  "{\n  \"env\": {}\n}\n",
+		},
+		{
+			name:  "delete the only member with a trailing comment",
+			input: "{\n  \"env\": {\n    \"A\": \"1\" // mine\n  }\n}\n",
+			edits: []edit{del("env", "A")},
+			want:  "{\n  \"env\": {}\n}\n",
+		},
+		{
+			name:  "delete the only inline member",
+			input: `{"env":{"A":"1"}}`,
+			edits: []edit{del("env", "A")},
+			want:  `{"env":{}}`,
+		},
+		{
+			name:  "delete keeps comment-only object",
+			input: "{\n  \"env\": {\n    // keep me\n    \"A\": \"1\"\n  }\n}\n",
+			edits: []edit{del("env", "A")},
+			want:  "{\n  \"env\": {\n    // keep me\n  }\n}\n",
+		},
+		{
+			name: "delete drops a trailing line comment",
+			input: `{
+  "a": 1, // about a
+  "b": 2, // about b
+  "c": 3
+}
+`,
+			edits: []edit{del("b")},
+			want: `{
+  "a": 1, // about a
+  "c": 3
+}
+`,
+		},
+		{
+			name: "delete drops a trailing block comment",
+			input: `{
+  "a": 1, /* about a */
+  "b": 2, /* about b */
+  "c": 3
+}
+`,
+			edits: []edit{del("b")},
+			want: `{
+  "a": 1, /* about a */
+  "c": 3
+}
+`,
+		},
+		{
+			name: "delete last member drops its line comment",
+			input: `{
+  "a": 1, // about a
+  "b": 2 // about b
+}
+`,
+			edits: []edit{del("b")},
+			want: `{
+  "a": 1 // about a
+}
+`,
+		},
+		{
+			name: "delete last member drops its block comment",
+			input: `{
+  "a": 1,
+  "b": 2 /* about b */
+}
+`,
+			edits: []edit{del("b")},
+			want: `{
+  "a": 1
+}
+`,
+		},
+		{
+			name: "delete drops a multi-line block comment",
+			input: `{
+  "a": 1,
+  "b": 2 /* about b,
+            continued */
+}
+`,
+			edits: []edit{del("b")},
+			want: `{
+  "a": 1
+}
+`,
+		},
+		{
+			name: "delete keeps leading comments",
+			input: `{
+  "a": 1,
+  // about b
+  "b": 2
+}
+`,
+			edits: []edit{del("b")},
+			want: `{
+  "a": 1
+  // about b
+}
+`,
+		},
+		{
+			name:  "delete missing key is a no-op",
+			input: "{\n  \"a\": 1 // x\n}\n",
+			edits: []edit{del("b"), del("a", "b")},
+			want:  "{\n  \"a\": 1 // x\n}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := Parse([]byte(tt.input))
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			for _, e := range tt.edits {
+				if e.delete {
+					_, err = d.Delete(e.path)
+				} else {
+					err = d.Set(e.path, e.value)
+				}
+				if err != nil {
+					t.Fatalf("edit %v: %v", e.path, err)
+				}
+			}
+			if got := string(d.Bytes()); got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+			if _, err := Parse(d.Bytes()); err != nil {
+				t.Errorf("result does not parse: %v", err)
+			}
+		})
+	}
+}
+
+// settingsFile resembles a hand-edited ~/.claude/settings.json
+const settingsFile = `// Claude Code user settings
+{
+	"$schema": "https://json.schemastore.org/claude-code-settings.json",
+	/* permissions are shared with the team */
+	"permissions": {
+		"allow": ["Bash(npm run lint)", "Read(~/.zshrc)"],
+		"deny": [],
+	},
+	"env": {
+		"ANTHROPIC_BASE_URL": "https://api.deepseek.com/anthropic", // set by cc-portkey
+		/* keep */ "HTTPS_PROXY": "http://127.0.0.1:7890",
+		"ANTHROPIC_MODEL": "deepseek-chat",
+	},
+	"statusLine": {"type": "command", "command": "echo ,}"}, // inline
+	"model": "opus"
+}
+`
+
+func TestEditEnvLeavesRestUntouched(t *testing.T) {
+	for _, crlf := range []bool{false, true} {
+		input := settingsFile
+		if crlf {
+			input = strings.ReplaceAll(input, "\n", "\r\n")
+		}
+
+		d, err := Parse([]byte(input))
+		if err != nil {
+			t.Fatalf("Parse: %v", err)
+		}
+		env := d.root.members[findMember(d.root, "env")]
+		before := input[:env.keyStart]
+		after := input[env.value.end:]
+
+		edits := []edit{
+			del("env", "ANTHROPIC_BASE_URL"),
+			set("glm-4.6", "env", "ANTHROPIC_MODEL"),
+			set("3000000", "env", "API_TIMEOUT_MS"),
+			del("env", "MISSING"),
+		}
+		for _, e := range edits {
+			if e.delete {
+				_, err = d.Delete(e.path)
+			} else {
+				err = d.Set(e.path, e.value)
+			}
+			if err != nil {
+				t.Fatalf("edit %v: %v", e.path, err)
+			}
+		}
+
+		got := d.Bytes()
+		if !bytes.HasPrefix(got, []byte(before)) {
+			t.Errorf("crlf=%v: bytes before env changed:\n%s", crlf, got)
+		}
+		if !bytes.HasSuffix(got, []byte(after)) {
+			t.Errorf("crlf=%v: bytes after env changed:\n%s", crlf, got)
+		}
+
+		v, err := d.Value()
+		if err != nil {
+			t.Fatalf("Value: %v", err)
+		}
+		wantEnv := map[string]interface{}{
+			"HTTPS_PROXY":     "http://127.0.0.1:7890",
+			"ANTHROPIC_MODEL": "glm-4.6",
+			"API_TIMEOUT_MS":  "3000000",
+		}
+		gotEnv := v["env"].(map[string]interface{})
+		if len(gotEnv) != len(wantEnv) {
+			t.Errorf("crlf=%v: env = %v, want %v", crlf, gotEnv, wantEnv)
+		}
+		for k, want := range wantEnv {
+			if gotEnv[k] != want {
+				t.Errorf("crlf=%v: env[%s] = %v, want %v", crlf, k, gotEnv[k], want)
+			}
+		}
+	}
+}
@@ -0,0 +1,316 @@
+// Package jsonc parses and edits JSON with comments and trailing commas
+// (the format Claude Code accepts for settings.json) while preserving the
+// original text outside of the edited values.
+package jsonc
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// kind is the type of a parsed JSON value
+type kind int
+
+const (
+	kindObject kind = iota
+	kindArray
+	kindString
+	kindNumber
+	kindLiteral // true, false, null
+)
+
+// node is a parsed value along with its byte offsets in the source
+type node struct {
+	kind     kind
+	start    int // offset of the first byte of the value
+	end      int // offset just past the last byte of the value
+	members  []member
+	elements []*node
+}
+
+// member is one key/value pair of an object
+type member struct {
+	key      string
+	keyStart int
+	value    *node
+	comma    int // offset of the comma following the value, -1 if none
+}
+
+// SyntaxError reports where a document failed to parse
+type SyntaxError struct {
+	Msg    string
+	Offset int
+	Line   int
+	Column int
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Msg)
+}
+
+// parser is a recursive-descent JSONC parser over a byte slice
+type parser struct {
+	data []byte
+	pos  int
+}
+
+// parse parses a complete document
+func parse(data []byte) (*node, error) {
+	p := &parser{data: data}
+	if err := p.skip(); err != nil {
+		return nil, err
+	}
+	if p.pos >= len(p.data) {
+		return nil, nil
+	}
+	n, err := p.value()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.skip(); err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.data) {
+		return nil, p.errorf("unexpected %q after top-level value", p.data[p.pos])
+	}
+	return n, nil
+}
+
+// errorf builds a SyntaxError at the current position
+func (p *parser) errorf(format string, args ...interface{}) error {
+	line, col := position(p.data, p.pos)
+	return &SyntaxError{Msg: fmt.Sprintf(format, args...), Offset: p.pos, Line: line, Column: col}
+}
+
+// position converts a byte offset into a 1-based line and column
+func position(data []byte, offset int) (int, int) {
+	line, col := 1, 1
+	for i := 0; i < offset && i < len(data); i++ {
+		if data[i] == '\n' {
+			line++
+			col = 1
+		} else {
+			col++
+		}
+	}
+	return line, col
+}
+
+// skip advances past whitespace and comments
+func (p *parser) skip() error {
+	for p.pos < len(p.data) {
+		switch c := p.data[p.pos]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			p.pos++
+		case c == '/' && p.pos+1 < len(p.data) && p.data[p.pos+1] == '/':
+			for p.pos < len(p.data) && p.data[p.pos] != '\n' {
+				p.pos++
+			}
+		case c == '/' && p.pos+1 < len(p.data) && p.data[p.pos+1] == '*':
+			end := strings.Index(string(p.data[p.pos+2:]), "*/")
+			if end < 0 {
+				return p.errorf("unterminated block comment")
+			}
+			p.pos += end + 4
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+// value parses any JSON value at the current position
+func (p *parser) value() (*node, error) {
+	if p.pos >= len(p.data) {
+		return nil, p.errorf("unexpected end of input")
+	}
+	switch c := p.data[p.pos]; {
+	case c == '{':
+		return p.object()
+	case c == '[':
+		return p.array()
+	case c == '"':
+		start := p.pos
+		if err := p.string(); err != nil {
+			return nil, err
+		}
+		return &node{kind: kindString, start: start, end: p.pos}, nil
+	case c == '-' || (c >= '0' && c <= '9'):
+		return p.number()
+	default:
+		for _, lit := range []string{"true", "false", "null"} {
+			if strings.HasPrefix(string(p.data[p.pos:]), lit) {
+				start := p.pos
+				p.pos += len(lit)
+				return &node{kind: kindLiteral, start: start, end: p.pos}, nil
+			}
+		}
+		return nil, p.errorf("unexpected %q", c)
+	}
+}
+
+// object parses an object, allowing a trailing comma
+func (p *parser) object() (*node, error) {
+	n := &node{kind: kindObject, start: p.pos}
+	p.pos++ // {
+
+	for {
+		if err := p.skip(); err != nil {
+			return nil, err
+		}
+		if p.pos >= len(p.data) {
+			return nil, p.errorf("unterminated object")
+		}
+		if p.data[p.pos] == '}' {
+			p.pos++
+			n.end = p.pos
+			return n, nil
+		}
+		if len(n.members) > 0 && n.members[len(n.members)-1].comma < 0 {
+			return nil, p.errorf("expected ',' or '}' in object")
+		}
+		if p.data[p.pos] != '"' {
+			return nil, p.errorf("expected string key in object")
+		}
+
+		keyStart := p.pos
+		if err := p.string(); err != nil {
+			return nil, err
+		}
+		var key string
+		if err := json.Unmarshal(p.data[keyStart:p.pos], &key); err != nil {
+			return nil, p.errorf("invalid key: %v", err)
+		}
+
+		if err := p.skip(); err != nil {
+			return nil, err
+		}
+		if p.pos >= len(p.data) || p.data[p.pos] != ':' {
+			return nil, p.errorf("expected ':' after key %q", key)
+		}
+		p.pos++
+		if err := p.skip(); err != nil {
+			return nil, err
+		}
+
+		v, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		m := member{key: key, keyStart: keyStart, value: v, comma: -1}
+
+		if err := p.skip(); err != nil {
+			return nil, err
+		}
+		if p.pos < len(p.data) && p.data[p.pos] == ',' {
+			m.comma = p.pos
+			p.pos++
+		}
+		n.members = append(n.members, m)
+	}
+}
+
+// array parses an array, allowing a trailing comma
+func (p *parser) array() (*node, error) {
+	n := &node{kind: kindArray, start: p.pos}
+	p.pos++ // [
+	needComma := false
+
+	for {
+		if err := p.skip(); err != nil {
+			return nil, err
+		}
+		if p.pos >= len(p.data) {
+			return nil, p.errorf("unterminated array")
+		}
+		if p.data[p.pos] == ']' {
+			p.pos++
+			n.end = p.pos
+			return n, nil
+		}
+		if needComma {
+			return nil, p.errorf("expected ',' or ']' in array")
+		}
+
+		v, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		n.elements = append(n.elements, v)
+
+		if err := p.skip(); err != nil {
+			return nil, err
+		}
+		needComma = true
+		if p.pos < len(p.data) && p.data[p.pos] == ',' {
+			p.pos++
+			needComma = false
+		}
+	}
+}
+
+// string advances past a string literal
+func (p *parser) string() error {
+	p.pos++ // opening quote
+	for p.pos < len(p.data) {
+		switch p.data[p.pos] {
+		case '\\':
+			p.pos += 2
+		case '"':
+			p.pos++
+			return nil
+		case '\n':
+			return p.errorf("newline in string")
+		default:
+			p.pos++
+		}
+	}
+	return p.errorf("unterminated string")
+}
+
+// number advances past a number literal and validates it
+func (p *parser) number() (*node, error) {
+	start := p.pos
+	for p.pos < len(p.data) && strings.IndexByte("+-0123456789.eE", p.data[p.pos]) >= 0 {
+		p.pos++
+	}
+	if !json.Valid(p.data[start:p.pos]) {
+		p.pos = start
+		return nil, p.errorf("invalid number")
+	}
+	return &node{kind: kindNumber, start: start, end: p.pos}, nil
+}
+
+// decode converts a parsed node into the generic Go representation
+// used by encoding/json (map[string]interface{}, []interface{}, ...)
+func decode(data []byte, n *node) (interface{}, error) {
+	switch n.kind {
+	case kindObject:
+		m := make(map[string]interface{}, len(n.members))
+		for _, mem := range n.members {
+			v, err := decode(data, mem.value)
+			if err != nil {
+				return nil, err
+			}
+			m[mem.key] = v
+		}
+		return m, nil
+	case kindArray:
+		a := make([]interface{}, 0, len(n.elements))
+		for _, el := range n.elements {
+			v, err := decode(data, el)
+			if err != nil {
+				return nil, err
+			}
+			a = append(a, v)
+		}
+		return a, nil
+	default:
+		var v interface{}
+		if err := json.Unmarshal(data[n.start:n.end], &v); err != nil {
+			return nil, err
+		}
+		return v, nil
+	}
+}
@@ -0,0 +1,291 @@
+// Package keys chooses among a profile's API keys and remembers the choice,
+// along with keys marked exhausted, in ~/.cc-portkey/keys.json.
+package keys
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/nanmi/cc-portkey/internal/config"
+	"github.com/nanmi/cc-portkey/internal/fileutil"
+)
+
+const stateFileName = "keys.json"
+
+// profileState is the rotation state of one profile. Keys are identified by
+// keyID rather than their position, so reordering or removing entries of
+// api_key leaves the state of the other keys where it belongs.
+type profileState struct {
+	Current   string               `json:"current,omitempty"`
+	Exhausted map[string]time.Time `json:"exhausted,omitempty"` // key ID -> end of cooldown
+	Projects  map[string]string    `json:"projects,omitempty"`  // project root -> key ID, for sticky_per_project
+}
+
+// state is the contents of keys.json
+type state struct {
+	Profiles map[string]*profileState `json:"profiles"`
+}
+
+// statePath returns the path to the rotation state file
+func statePath() (string, error) {
+	dir, err := config.ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, stateFileName), nil
+}
+
+// load reads the state file, returning an empty state if it doesn't exist
+func load() (*state, error) {
+	path, err := statePath()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &state{}
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read key state: %w", err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, s); err != nil {
+			return nil, fmt.Errorf("failed to parse key state %s: %w", path, err)
+		}
+	}
+	if s.Profiles == nil {
+		s.Profiles = make(map[string]*profileState)
+	}
+	return s, nil
+}
+
+// update runs fn on the state under the state lock and saves the result
+func update(fn func(s *state) error) error {
+	path, err := statePath()
+	if err != nil {
+		return err
+	}
+	return fileutil.WithLock(path+".lock", func() error {
+		s, err := load()
+		if err != nil {
+			return err
+		}
+		if err := fn(s); err != nil {
+			return err
+		}
+
+		data, err := json.MarshalIndent(s, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal key state: %w", err)
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return fmt.Errorf("failed to create config directory: %w", err)
+		}
+		if err := fileutil.WriteFileAtomic(path, data, 0600); err != nil {
+			return fmt.Errorf("failed to save key state: %w", err)
+		}
+		return nil
+	})
+}
+
+// profile returns the state of a profile, creating it if needed
+func (s *state) profile(name string) *profileState {
+	ps, ok := s.Profiles[name]
+	if !ok {
+		ps = &profileState{}
+		s.Profiles[name] = ps
+	}
+	return ps
+}
+
+// available reports whether the key with the given ID is out of its
+// cooldown at now
+func (ps *profileState) available(id string, now time.Time) bool {
+	until, ok := ps.Exhausted[id]
+	return !ok || !now.Before(until)
+}
+
+// keyID identifies an api_key entry in keys.json without storing the key
+func keyID(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:8])
+}
+
+// keyIDs returns the IDs of a profile's keys, in api_key order
+func keyIDs(profile *config.Profile) []string {
+	ids := make([]string, len(profile.APIKey))
+	for i, key := range profile.APIKey {
+		ids[i] = keyID(key)
+	}
+	return ids
+}
+
+// indexOf returns the index of the key with the given ID, or -1
+func indexOf(ids []string, id string) int {
+	for i, k := range ids {
+		if k == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// ValidateStrategy checks a profile's key_strategy
+func ValidateStrategy(strategy string) error {
+	switch strategy {
+	case "", config.KeyRoundRobin, config.KeyRandom, config.KeyStickyPerProject:
+		return nil
+	}
+	return fmt.Errorf("unknown key_strategy %q (expected %s, %s or %s)",
+		strategy, config.KeyRoundRobin, config.KeyRandom, config.KeyStickyPerProject)
+}
+
+// Select picks the key to use for the named profile according to its
+// key_strategy, skipping exhausted keys, and records the choice.
+// project is the project root, used by sticky_per_project.
+// Profiles with a single key always use it.
+func Select(name string, profile *config.Profile, project string) (int, error) {
+	n := profile.KeyCount()
+	if n <= 1 {
+		return 0, nil
+	}
+	if err := ValidateStrategy(profile.KeyStrategy); err != nil {
+		return 0, err
+	}
+
+	ids := keyIDs(profile)
+	var chosen int
+	err := update(func(s *state) error {
+		// A profile seen for the first time, or whose last key was removed,
+		// starts with its first key
+		last := -1
+		if ps, ok := s.Profiles[name]; ok {
+			last = indexOf(ids, ps.Current)
+		}
+		ps := s.profile(name)
+		now := time.Now()
+
+		var candidates []int
+		for i, id := range ids {
+			if ps.available(id, now) {
+				candidates = append(candidates, i)
+			}
+		}
+		if len(candidates) == 0 {
+			return fmt.Errorf("all %d keys of profile '%s' are exhausted; the first becomes available at %s",
+				n, name, earliest(ps, ids).Local().Format("15:04:05"))
+		}
+
+		switch profile.KeyStrategy {
+		case config.KeyRandom:
+			chosen = candidates[rand.Intn(len(candidates))]
+		case config.KeyStickyPerProject:
+			if i := indexOf(ids, ps.Projects[project]); i >= 0 && ps.available(ids[i], now) {
+				chosen = i
+				break
+			}
+			chosen = nextAfter(last, candidates)
+			if ps.Projects == nil {
+				ps.Projects = make(map[string]string)
+			}
+			ps.Projects[project] = ids[chosen]
+		default:
+			chosen = nextAfter(last, candidates)
+		}
+
+		ps.Current = ids[chosen]
+		return nil
+	})
+	return chosen, err
+}
+
+// nextAfter returns the first candidate after current, wrapping around
+func nextAfter(current int, candidates []int) int {
+	for _, i := range candidates {
+		if i > current {
+			return i
+		}
+	}
+	return candidates[0]
+}
+
+// earliest returns when the first of the given exhausted keys becomes
+// available again
+func earliest(ps *profileState, ids []string) time.Time {
+	var first time.Time
+	for _, id := range ids {
+		if until := ps.Exhausted[id]; first.IsZero() || until.Before(first) {
+			first = until
+		}
+	}
+	return first
+}
+
+// Current returns the key index last selected for the named profile
+func Current(name string, profile *config.Profile) int {
+	s, err := load()
+	if err != nil {
+		return 0
+	}
+	ps, ok := s.Profiles[name]
+	if !ok {
+		return 0
+	}
+	if i := indexOf(keyIDs(profile), ps.Current); i >= 0 {
+		return i
+	}
+	return 0
+}
+
+// MarkExhausted takes key i of the named profile out of rotation until
+// cooldown has passed
+func MarkExhausted(name string, profile *config.Profile, i int, cooldown time.Duration) error {
+	if i < 0 || i >= profile.KeyCount() {
+		return fmt.Errorf("profile '%s' has no key %d (it has %d)", name, i+1, profile.KeyCount())
+	}
+	if cooldown < 0 {
+		return fmt.Errorf("cooldown must not be negative (got %s)", cooldown)
+	}
+	id := keyID(profile.APIKey[i])
+	return update(func(s *state) error {
+		ps := s.profile(name)
+		if ps.Exhausted == nil {
+			ps.Exhausted = make(map[string]time.Time)
+		}
+		ps.Exhausted[id] = time.Now().Add(cooldown).UTC()
+
+		// Forget sticky choices of the exhausted key
+		for project, k := range ps.Projects {
+			if k == id {
+				delete(ps.Projects, project)
+			}
+		}
+		return nil
+	})
+}
+
+// ExhaustedUntil returns, for each key of the named profile still in its
+// cooldown, when the cooldown ends. The map is keyed by index into api_key.
+func ExhaustedUntil(name string, profile *config.Profile) map[int]time.Time {
+	s, err := load()
+	if err != nil {
+		return nil
+	}
+	ps, ok := s.Profiles[name]
+	if !ok {
+		return nil
+	}
+	now := time.Now()
+	result := make(map[int]time.Time)
+	for i, id := range keyIDs(profile) {
+		if until, ok := ps.Exhausted[id]; ok && now.Before(until) {
+			result[i] = until
+		}
+	}
+	return result
+}
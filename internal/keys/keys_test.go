@@ -0,0 +1,215 @@
+package keys
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nanmi/cc-portkey/internal/config"
+)
+
+// isolate keeps keys.json in a temporary config directory
+func isolate(t *testing.T) {
+	t.Helper()
+	t.Setenv(config.ConfigEnvVar, filepath.Join(t.TempDir(), "config.json"))
+}
+
+// pool returns a profile with the given keys and strategy
+func pool(strategy string, keys ...string) *config.Profile {
+	return &config.Profile{APIKey: config.StringList(keys), KeyStrategy: strategy}
+}
+
+// selectN runs Select n times and returns the chosen indexes
+func selectN(t *testing.T, name string, profile *config.Profile, project string, n int) []int {
+	t.Helper()
+	var chosen []int
+	for i := 0; i < n; i++ {
+		k, err := Select(name, profile, project)
+		if err != nil {
+			t.Fatalf("Select: %v", err)
+		}
+		chosen = append(chosen, k)
+	}
+	return chosen
+}
+
+func TestSelect(t *testing.T) {
+	tests := []struct {
+		name      string
+		profile   *config.Profile
+		exhausted []int // marked before selecting
+		projects  []string
+		want      []int
+	}{
+		{
+			name:     "single key",
+			profile:  pool("", "sk-1"),
+			projects: []string{"/a", "/a"},
+			want:     []int{0, 0},
+		},
+		{
+			name:     "round robin",
+			profile:  pool(config.KeyRoundRobin, "sk-1", "sk-2", "sk-3"),
+			projects: []string{"/a", "/a", "/a", "/a"},
+			want:     []int{0, 1, 2, 0},
+		},
+		{
+			name:      "round robin skips exhausted",
+			profile:   pool("", "sk-1", "sk-2", "sk-3"),
+			exhausted: []int{1},
+			projects:  []string{"/a", "/a", "/a"},
+			want:      []int{0, 2, 0},
+		},
+		{
+			name:     "sticky per project",
+			profile:  pool(config.KeyStickyPerProject, "sk-1", "sk-2"),
+			projects: []string{"/a", "/b", "/a", "/b", "/c"},
+			want:     []int{0, 1, 0, 1, 0},
+		},
+		{
+			name:      "sticky skips exhausted",
+			profile:   pool(config.KeyStickyPerProject, "sk-1", "sk-2", "sk-3"),
+			exhausted: []int{0},
+			projects:  []string{"/a", "/b", "/a"},
+			want:      []int{1, 2, 1},
+		},
+		{
+			name:      "random only picks available keys",
+			profile:   pool(config.KeyRandom, "sk-1", "sk-2", "sk-3"),
+			exhausted: []int{0, 2},
+			projects:  []string{"/a", "/a", "/a", "/a"},
+			want:      []int{1, 1, 1, 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			isolate(t)
+			for _, i := range tt.exhausted {
+				if err := MarkExhausted("p", tt.profile, i, time.Hour); err != nil {
+					t.Fatalf("MarkExhausted: %v", err)
+				}
+			}
+
+			var got []int
+			for _, project := range tt.projects {
+				got = append(got, selectN(t, "p", tt.profile, project, 1)...)
+			}
+			if !equalInts(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+			if last := got[len(got)-1]; Current("p", tt.profile) != last {
+				t.Errorf("Current = %d, want the last selected key %d", Current("p", tt.profile), last)
+			}
+		})
+	}
+}
+
+func TestSelectAllExhausted(t *testing.T) {
+	isolate(t)
+	profile := pool("", "sk-1", "sk-2")
+	for i := range profile.APIKey {
+		if err := MarkExhausted("p", profile, i, time.Hour); err != nil {
+			t.Fatalf("MarkExhausted: %v", err)
+		}
+	}
+
+	_, err := Select("p", profile, "/a")
+	if err == nil || !strings.Contains(err.Error(), "all 2 keys of profile 'p' are exhausted") {
+		t.Errorf("got %v, want an all-exhausted error", err)
+	}
+}
+
+func TestCooldown(t *testing.T) {
+	isolate(t)
+	profile := pool("", "sk-1", "sk-2")
+
+	// A zero cooldown ends right away
+	if err := MarkExhausted("p", profile, 1, 0); err != nil {
+		t.Fatalf("MarkExhausted: %v", err)
+	}
+	if got := ExhaustedUntil("p", profile); len(got) != 0 {
+		t.Errorf("ExhaustedUntil = %v, want nothing in cooldown", got)
+	}
+	if got := selectN(t, "p", profile, "/a", 2); !equalInts(got, []int{0, 1}) {
+		t.Errorf("got %v, want both keys in turn", got)
+	}
+
+	before := time.Now()
+	if err := MarkExhausted("p", profile, 1, time.Hour); err != nil {
+		t.Fatalf("MarkExhausted: %v", err)
+	}
+	until, ok := ExhaustedUntil("p", profile)[1]
+	if !ok || until.Before(before.Add(time.Hour)) || until.After(time.Now().Add(time.Hour)) {
+		t.Errorf("key 2 exhausted until %v (%v), want an hour from now", until, ok)
+	}
+
+	if err := MarkExhausted("p", profile, 0, -time.Minute); err == nil || !strings.Contains(err.Error(), "must not be negative") {
+		t.Errorf("got %v, want a negative cooldown error", err)
+	}
+	for _, i := range []int{-1, 2} {
+		if err := MarkExhausted("p", profile, i, time.Hour); err == nil || !strings.Contains(err.Error(), "has no key") {
+			t.Errorf("key %d: got %v, want a no such key error", i, err)
+		}
+	}
+}
+
+func TestStateFollowsKeys(t *testing.T) {
+	isolate(t)
+	profile := pool(config.KeyStickyPerProject, "sk-1", "sk-2", "sk-3")
+
+	if got := selectN(t, "p", profile, "/a", 1); got[0] != 0 {
+		t.Fatalf("got key %d for /a, want 0", got[0])
+	}
+	if got := selectN(t, "p", profile, "/b", 1); got[0] != 1 {
+		t.Fatalf("got key %d for /b, want 1", got[0])
+	}
+	if err := MarkExhausted("p", profile, 2, time.Hour); err != nil {
+		t.Fatalf("MarkExhausted: %v", err)
+	}
+
+	// Reorder the keys and drop sk-1: sk-2 is still /b's key and in use,
+	// sk-3 is still exhausted, and /a moves on
+	profile = pool(config.KeyStickyPerProject, "sk-3", "sk-2", "sk-4")
+	if got := Current("p", profile); got != 1 {
+		t.Errorf("Current = %d, want 1 (sk-2)", got)
+	}
+	exhausted := ExhaustedUntil("p", profile)
+	if _, ok := exhausted[0]; !ok || len(exhausted) != 1 {
+		t.Errorf("ExhaustedUntil = %v, want only index 0 (sk-3)", exhausted)
+	}
+	if got := selectN(t, "p", profile, "/b", 1); got[0] != 1 {
+		t.Errorf("got key %d for /b, want 1 (sk-2)", got[0])
+	}
+	if got := selectN(t, "p", profile, "/a", 1); got[0] != 2 {
+		t.Errorf("got key %d for /a, want 2 (sk-4)", got[0])
+	}
+}
+
+func TestValidateStrategy(t *testing.T) {
+	for _, s := range []string{"", config.KeyRoundRobin, config.KeyRandom, config.KeyStickyPerProject} {
+		if err := ValidateStrategy(s); err != nil {
+			t.Errorf("ValidateStrategy(%q): %v", s, err)
+		}
+	}
+	if err := ValidateStrategy("least_used"); err == nil {
+		t.Error("ValidateStrategy accepted an unknown strategy")
+	}
+	if _, err := Select("p", pool("least_used", "sk-1", "sk-2"), "/a"); err == nil {
+		t.Error("Select accepted an unknown strategy")
+	}
+}
+
+// equalInts reports whether two int slices are equal
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
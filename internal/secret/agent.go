@@ -0,0 +1,201 @@
+package secret
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/nanmi/cc-portkey/internal/config"
+)
+
+const (
+	agentDirName    = "agent"
+	agentSocketName = "agent.sock"
+
+	// AgentTTLEnvVar sets how long a prompted passphrase is cached, e.g. "1h".
+	// Zero disables the agent.
+	AgentTTLEnvVar = "CC_PORTKEY_AGENT_TTL"
+
+	// DefaultAgentTTL is how long the agent caches the passphrase by default
+	DefaultAgentTTL = 15 * time.Minute
+
+	agentTimeout = 2 * time.Second
+)
+
+// agentSocketPath returns the agent's socket, inside a directory only the
+// current user can enter
+func agentSocketPath() (string, error) {
+	dir, err := config.ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, agentDirName, agentSocketName), nil
+}
+
+// AgentTTL returns how long the agent should cache the passphrase
+func AgentTTL() (time.Duration, error) {
+	value := os.Getenv(AgentTTLEnvVar)
+	if value == "" {
+		return DefaultAgentTTL, nil
+	}
+	if value == "0" {
+		return 0, nil
+	}
+	ttl, err := time.ParseDuration(value)
+	if err != nil || ttl < 0 {
+		return 0, fmt.Errorf("invalid %s %q: expected a duration such as 30m or 2h", AgentTTLEnvVar, value)
+	}
+	return ttl, nil
+}
+
+// RunAgent serves passphrase on the agent socket until ttl elapses or the
+// agent is stopped
+func RunAgent(passphrase string, ttl time.Duration) error {
+	path, err := agentSocketPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create agent directory: %w", err)
+	}
+	if err := os.Chmod(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to secure agent directory: %w", err)
+	}
+
+	// Replace a socket left behind by a crashed agent, but never a live one
+	if AgentRunning() {
+		return fmt.Errorf("an agent is already running")
+	}
+	os.Remove(path)
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", path, err)
+	}
+	defer ln.Close()
+	if err := os.Chmod(path, 0600); err != nil {
+		return fmt.Errorf("failed to secure agent socket: %w", err)
+	}
+
+	expiry := time.AfterFunc(ttl, func() { ln.Close() })
+	defer expiry.Stop()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			// Closed by expiry or a stop request
+			return nil
+		}
+		if stop := serveAgentConn(conn, passphrase); stop {
+			return nil
+		}
+	}
+}
+
+// serveAgentConn answers a single request, reporting whether the agent
+// was asked to stop
+func serveAgentConn(conn net.Conn, passphrase string) bool {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(agentTimeout))
+
+	request, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return false
+	}
+	switch strings.TrimSpace(request) {
+	case "get":
+		fmt.Fprintln(conn, passphrase)
+	case "stop":
+		fmt.Fprintln(conn, "ok")
+		return true
+	}
+	return false
+}
+
+// queryAgent sends a request to the agent and returns its reply
+func queryAgent(request string) (string, error) {
+	path, err := agentSocketPath()
+	if err != nil {
+		return "", err
+	}
+	conn, err := net.DialTimeout("unix", path, agentTimeout)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(agentTimeout))
+
+	if _, err := fmt.Fprintln(conn, request); err != nil {
+		return "", err
+	}
+	reply, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(reply, "\n"), "\r"), nil
+}
+
+// agentPassphrase asks a running agent for the cached passphrase
+func agentPassphrase() (string, bool) {
+	passphrase, err := queryAgent("get")
+	if err != nil || passphrase == "" {
+		return "", false
+	}
+	return passphrase, true
+}
+
+// AgentRunning reports whether an agent is answering on the socket
+func AgentRunning() bool {
+	_, ok := agentPassphrase()
+	return ok
+}
+
+// StopAgent stops a running agent, reporting whether one was running
+func StopAgent() bool {
+	_, err := queryAgent("stop")
+	return err == nil
+}
+
+// StartAgent starts a background agent caching passphrase for ttl.
+// The passphrase is handed over on the agent's stdin so it never
+// appears in its arguments or environment.
+func StartAgent(passphrase string, ttl time.Duration) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to locate cc-portkey executable: %w", err)
+	}
+
+	// Pin the config so the agent serves this config's vault and socket
+	configPath, err := config.ConfigPath()
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command(exe, "--config", configPath, "secret", "agent", "--ttl", ttl.String())
+	detach(cmd)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return fmt.Errorf("failed to start agent: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start agent: %w", err)
+	}
+	fmt.Fprintln(stdin, passphrase)
+	stdin.Close()
+	cmd.Process.Release()
+
+	// Wait for the agent to start answering
+	deadline := time.Now().Add(agentTimeout)
+	for time.Now().Before(deadline) {
+		if AgentRunning() {
+			return nil
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return fmt.Errorf("agent did not start within %s", agentTimeout)
+}
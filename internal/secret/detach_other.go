@@ -0,0 +1,8 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd || windows)
+
+package secret
+
+import "os/exec"
+
+// detach is a no-op on platforms without session control
+func detach(cmd *exec.Cmd) {}
@@ -0,0 +1,187 @@
+package secret
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/nanmi/cc-portkey/internal/config"
+	"golang.org/x/term"
+)
+
+// PassphraseEnvVar supplies the vault passphrase non-interactively, e.g. in CI
+const PassphraseEnvVar = "CC_PORTKEY_PASSPHRASE"
+
+// namePattern restricts secret names to what fits in a ${secret:NAME} reference
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// ValidateName checks that name can be used in a ${secret:NAME} reference
+func ValidateName(name string) error {
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("invalid secret name %q: use letters, digits, '_', '-' and '.'", name)
+	}
+	return nil
+}
+
+// Unlock opens the vault with the passphrase from $CC_PORTKEY_PASSPHRASE,
+// a running agent or a terminal prompt. A prompted passphrase is cached
+// in a new agent for AgentTTL.
+func Unlock() (*Vault, error) {
+	if !VaultExists() {
+		return nil, fmt.Errorf("no secret vault found. Add a secret with 'cc-portkey secret set <name>'")
+	}
+
+	if passphrase := os.Getenv(PassphraseEnvVar); passphrase != "" {
+		return Open(passphrase)
+	}
+
+	if passphrase, ok := agentPassphrase(); ok {
+		v, err := Open(passphrase)
+		if !errors.Is(err, ErrWrongPassphrase) {
+			return v, err
+		}
+		// The passphrase changed since the agent started
+		StopAgent()
+	}
+
+	passphrase, err := promptPassword("Vault passphrase: ")
+	if err != nil {
+		return nil, err
+	}
+	v, err := Open(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	cachePassphrase(passphrase)
+	return v, nil
+}
+
+// Passphrase returns the vault passphrase the way Unlock obtains it.
+// A new vault asks for its passphrase twice.
+func Passphrase() (string, error) {
+	if passphrase := os.Getenv(PassphraseEnvVar); passphrase != "" {
+		return passphrase, nil
+	}
+
+	if !VaultExists() {
+		passphrase, err := promptPassword("New vault passphrase: ")
+		if err != nil {
+			return "", err
+		}
+		if passphrase == "" {
+			return "", fmt.Errorf("passphrase must not be empty")
+		}
+		confirm, err := promptPassword("Repeat passphrase: ")
+		if err != nil {
+			return "", err
+		}
+		if confirm != passphrase {
+			return "", fmt.Errorf("passphrases do not match")
+		}
+		cachePassphrase(passphrase)
+		return passphrase, nil
+	}
+
+	if passphrase, ok := agentPassphrase(); ok {
+		return passphrase, nil
+	}
+	passphrase, err := PromptPassphrase()
+	if err != nil {
+		return "", err
+	}
+	cachePassphrase(passphrase)
+	return passphrase, nil
+}
+
+// PromptPassphrase asks for the passphrase of the existing vault and checks it
+func PromptPassphrase() (string, error) {
+	passphrase, err := promptPassword("Vault passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	if _, err := Open(passphrase); err != nil {
+		return "", err
+	}
+	return passphrase, nil
+}
+
+// cachePassphrase hands passphrase to a new agent unless caching is disabled.
+// Failing to start the agent only costs a prompt next time.
+func cachePassphrase(passphrase string) {
+	ttl, err := AgentTTL()
+	if err != nil || ttl == 0 || AgentRunning() {
+		return
+	}
+	if err := StartAgent(passphrase, ttl); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to cache passphrase: %v\n", err)
+	}
+}
+
+// ReadValue reads a secret value from the terminal without echoing it,
+// or all of stdin when input is redirected
+func ReadValue(prompt string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return "", fmt.Errorf("failed to read value: %w", err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	}
+
+	fmt.Fprint(os.Stderr, prompt)
+	data, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("failed to read value: %w", err)
+	}
+	return string(data), nil
+}
+
+// promptPassword reads a line from the terminal without echoing it
+func promptPassword(prompt string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", fmt.Errorf("the secret vault is locked and there is no terminal to ask for its passphrase. Run 'cc-portkey secret unlock' first or set %s", PassphraseEnvVar)
+	}
+
+	fmt.Fprint(os.Stderr, prompt)
+	data, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("failed to read passphrase: %w", err)
+	}
+	return string(data), nil
+}
+
+// opened caches the vault unlocked by resolve for the rest of the process
+var opened *Vault
+
+// resolve looks up a ${secret:NAME} reference
+func resolve(name string) (string, error) {
+	if opened == nil {
+		v, err := Unlock()
+		if err != nil {
+			return "", err
+		}
+		opened = v
+	}
+
+	value, ok := opened.Get(name)
+	if !ok {
+		return "", fmt.Errorf("secret %q not found. Add it with 'cc-portkey secret set %s'", name, name)
+	}
+	return value, nil
+}
+
+func init() {
+	config.RegisterResolver("secret", resolve)
+}
+
+// Available reports whether the vault can be unlocked without prompting
+func Available() bool {
+	return os.Getenv(PassphraseEnvVar) != "" || AgentRunning()
+}
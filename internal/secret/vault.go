@@ -0,0 +1,233 @@
+// Package secret implements the passphrase-encrypted vault behind
+// ${secret:NAME} references and the agent that caches its passphrase.
+package secret
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/nanmi/cc-portkey/internal/config"
+	"github.com/nanmi/cc-portkey/internal/fileutil"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	vaultFileName = "secrets.json"
+	vaultVersion  = 1
+
+	// scrypt parameters for new vaults
+	scryptN   = 1 << 15
+	scryptR   = 8
+	scryptP   = 1
+	keyLength = 32
+	saltSize  = 16
+)
+
+// ErrWrongPassphrase is returned when the vault can't be decrypted
+var ErrWrongPassphrase = errors.New("wrong passphrase")
+
+// vaultFile is the on-disk format. Only the secrets are encrypted;
+// the KDF parameters are stored alongside so they can change later.
+type vaultFile struct {
+	Version int    `json:"version"`
+	KDF     string `json:"kdf"`
+	N       int    `json:"n"`
+	R       int    `json:"r"`
+	P       int    `json:"p"`
+	Salt    []byte `json:"salt"`
+	Nonce   []byte `json:"nonce"`
+	Data    []byte `json:"data"`
+}
+
+// Vault holds decrypted secrets in memory
+type Vault struct {
+	path    string
+	file    vaultFile
+	key     []byte
+	secrets map[string]string
+}
+
+// VaultPath returns the path to the encrypted vault file
+func VaultPath() (string, error) {
+	dir, err := config.ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, vaultFileName), nil
+}
+
+// VaultExists checks if a vault has been created
+func VaultExists() bool {
+	path, err := VaultPath()
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(path)
+	return err == nil
+}
+
+// Open decrypts the vault with passphrase.
+// A missing vault file opens as an empty vault that Save will create.
+func Open(passphrase string) (*Vault, error) {
+	path, err := VaultPath()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return newVault(path, passphrase)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read vault: %w", err)
+	}
+
+	v := &Vault{path: path}
+	if err := json.Unmarshal(data, &v.file); err != nil {
+		return nil, fmt.Errorf("failed to parse vault %s: %w", path, err)
+	}
+	if v.file.Version != vaultVersion || v.file.KDF != "scrypt" {
+		return nil, fmt.Errorf("unsupported vault format (version %d, kdf %q)", v.file.Version, v.file.KDF)
+	}
+
+	if v.key, err = scrypt.Key([]byte(passphrase), v.file.Salt, v.file.N, v.file.R, v.file.P, keyLength); err != nil {
+		return nil, fmt.Errorf("failed to derive vault key: %w", err)
+	}
+	gcm, err := newGCM(v.key)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := gcm.Open(nil, v.file.Nonce, v.file.Data, nil)
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	if err := json.Unmarshal(plain, &v.secrets); err != nil {
+		return nil, fmt.Errorf("failed to parse vault contents: %w", err)
+	}
+	if v.secrets == nil {
+		v.secrets = make(map[string]string)
+	}
+	return v, nil
+}
+
+// newVault prepares an empty vault encrypted with passphrase
+func newVault(path, passphrase string) (*Vault, error) {
+	v := &Vault{
+		path: path,
+		file: vaultFile{
+			Version: vaultVersion,
+			KDF:     "scrypt",
+			N:       scryptN,
+			R:       scryptR,
+			P:       scryptP,
+			Salt:    make([]byte, saltSize),
+		},
+		secrets: make(map[string]string),
+	}
+	if _, err := rand.Read(v.file.Salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	var err error
+	if v.key, err = scrypt.Key([]byte(passphrase), v.file.Salt, v.file.N, v.file.R, v.file.P, keyLength); err != nil {
+		return nil, fmt.Errorf("failed to derive vault key: %w", err)
+	}
+	return v, nil
+}
+
+// Get returns the secret stored under name
+func (v *Vault) Get(name string) (string, bool) {
+	value, ok := v.secrets[name]
+	return value, ok
+}
+
+// Set stores value under name
+func (v *Vault) Set(name, value string) {
+	v.secrets[name] = value
+}
+
+// Delete removes name, reporting whether it existed
+func (v *Vault) Delete(name string) bool {
+	_, ok := v.secrets[name]
+	delete(v.secrets, name)
+	return ok
+}
+
+// Names returns the stored secret names in sorted order
+func (v *Vault) Names() []string {
+	names := make([]string, 0, len(v.secrets))
+	for name := range v.secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Save encrypts the vault with a fresh nonce and writes it atomically
+func (v *Vault) Save() error {
+	plain, err := json.Marshal(v.secrets)
+	if err != nil {
+		return fmt.Errorf("failed to marshal secrets: %w", err)
+	}
+
+	gcm, err := newGCM(v.key)
+	if err != nil {
+		return err
+	}
+	v.file.Nonce = make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(v.file.Nonce); err != nil {
+		return fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	v.file.Data = gcm.Seal(nil, v.file.Nonce, plain, nil)
+
+	data, err := json.MarshalIndent(v.file, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal vault: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(v.path), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	fileutil.CleanTemps(v.path)
+	if err := fileutil.WriteFileAtomic(v.path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write vault: %w", err)
+	}
+	return nil
+}
+
+// Update opens the vault under the vault lock, calls fn and saves the result
+func Update(passphrase string, fn func(v *Vault) error) error {
+	path, err := VaultPath()
+	if err != nil {
+		return err
+	}
+	return fileutil.WithLock(path+".lock", func() error {
+		v, err := Open(passphrase)
+		if err != nil {
+			return err
+		}
+		if err := fn(v); err != nil {
+			return err
+		}
+		return v.Save()
+	})
+}
+
+// newGCM returns an AES-GCM cipher for key
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return gcm, nil
+}
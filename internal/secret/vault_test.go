@@ -0,0 +1,189 @@
+package secret
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/nanmi/cc-portkey/internal/config"
+)
+
+// isolate keeps the vault in a temporary config directory
+func isolate(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv(config.ConfigEnvVar, filepath.Join(dir, "config.json"))
+	return filepath.Join(dir, vaultFileName)
+}
+
+func TestVaultRoundTrip(t *testing.T) {
+	tests := []struct {
+		name       string
+		passphrase string
+		secrets    map[string]string
+	}{
+		{name: "empty vault", passphrase: "pw", secrets: map[string]string{}},
+		{name: "one secret", passphrase: "pw", secrets: map[string]string{"DEEPSEEK": "sk-ds-123"}},
+		{
+			name:       "several secrets",
+			passphrase: "correct horse battery staple",
+			secrets:    map[string]string{"A": "sk-a-111", "B": "", "C": "line\nbreak \"quoted\" 密钥"},
+		},
+		{name: "empty passphrase", passphrase: "", secrets: map[string]string{"A": "sk-a-111"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := isolate(t)
+			if VaultExists() {
+				t.Fatal("VaultExists before anything was saved")
+			}
+
+			v, err := Open(tt.passphrase)
+			if err != nil {
+				t.Fatalf("Open: %v", err)
+			}
+			for name, value := range tt.secrets {
+				v.Set(name, value)
+			}
+			if err := v.Save(); err != nil {
+				t.Fatalf("Save: %v", err)
+			}
+			if !VaultExists() {
+				t.Fatal("VaultExists after Save")
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, value := range tt.secrets {
+				if value != "" && bytes.Contains(data, []byte(value)) {
+					t.Errorf("vault file contains %q in the clear", value)
+				}
+			}
+
+			reopened, err := Open(tt.passphrase)
+			if err != nil {
+				t.Fatalf("reopen: %v", err)
+			}
+			if !reflect.DeepEqual(reopened.secrets, tt.secrets) {
+				t.Errorf("got %v, want %v", reopened.secrets, tt.secrets)
+			}
+		})
+	}
+}
+
+func TestVaultWrongPassphrase(t *testing.T) {
+	path := isolate(t)
+	if err := Update("right", func(v *Vault) error {
+		v.Set("A", "1")
+		return nil
+	}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	for _, passphrase := range []string{"wrong", "", "right "} {
+		if _, err := Open(passphrase); !errors.Is(err, ErrWrongPassphrase) {
+			t.Errorf("Open(%q) = %v, want ErrWrongPassphrase", passphrase, err)
+		}
+	}
+	err := Update("wrong", func(v *Vault) error {
+		t.Error("Update ran fn with the wrong passphrase")
+		return nil
+	})
+	if !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("Update = %v, want ErrWrongPassphrase", err)
+	}
+
+	// Tampered ciphertext fails authentication the same way
+	var file vaultFile
+	data, _ := os.ReadFile(path)
+	if err := json.Unmarshal(data, &file); err != nil {
+		t.Fatal(err)
+	}
+	file.Data[0] ^= 1
+	data, _ = json.Marshal(file)
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open("right"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("Open of a tampered vault = %v, want ErrWrongPassphrase", err)
+	}
+}
+
+func TestVaultUpdate(t *testing.T) {
+	path := isolate(t)
+	set := func(name, value string) func(v *Vault) error {
+		return func(v *Vault) error {
+			v.Set(name, value)
+			return nil
+		}
+	}
+	if err := Update("pw", set("B", "2")); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	before, _ := os.ReadFile(path)
+	if err := Update("pw", set("A", "1")); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	after, _ := os.ReadFile(path)
+
+	var f1, f2 vaultFile
+	json.Unmarshal(before, &f1)
+	json.Unmarshal(after, &f2)
+	if bytes.Equal(f1.Nonce, f2.Nonce) {
+		t.Error("Save reused the nonce")
+	}
+	if !bytes.Equal(f1.Salt, f2.Salt) {
+		t.Error("Save changed the salt of an existing vault")
+	}
+
+	if err := Update("pw", func(v *Vault) error {
+		if !v.Delete("B") || v.Delete("missing") {
+			t.Error("Delete reported the wrong result")
+		}
+		return nil
+	}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	v, err := Open("pw")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if got := strings.Join(v.Names(), ","); got != "A" {
+		t.Errorf("Names = %s, want A", got)
+	}
+	if value, ok := v.Get("A"); !ok || value != "1" {
+		t.Errorf("Get(A) = %q, %v", value, ok)
+	}
+
+	// A failing fn leaves the vault as it was
+	if err := Update("pw", func(v *Vault) error {
+		v.Set("C", "3")
+		return errors.New("stop")
+	}); err == nil || err.Error() != "stop" {
+		t.Errorf("Update = %v, want fn's error", err)
+	}
+	if v, _ := Open("pw"); v != nil {
+		if _, ok := v.Get("C"); ok {
+			t.Error("a failed Update was saved")
+		}
+	}
+}
+
+func TestVaultUnsupportedFormat(t *testing.T) {
+	path := isolate(t)
+	if err := os.WriteFile(path, []byte(`{"version": 2, "kdf": "argon2id"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open("pw"); err == nil || !strings.Contains(err.Error(), "unsupported vault format") {
+		t.Errorf("got %v, want an unsupported format error", err)
+	}
+}